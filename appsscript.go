@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	appsScriptInput        = "appsScript"
+	appsScriptMimeType     = "application/vnd.google-apps.script"
+	appsScriptImportFormat = "application/vnd.google-apps.script+json"
+	claspConfigName        = ".clasp.json"
+	appsScriptManifestName = "appsscript"
+)
+
+// scriptFile is a file of an Apps Script project in the import format of
+// Drive
+type scriptFile struct {
+	Id     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+type scriptBundle struct {
+	Files []scriptFile `json:"files"`
+}
+
+// claspConfig is the project configuration written by clasp
+type claspConfig struct {
+	ScriptId string `json:"scriptId"`
+	RootDir  string `json:"rootDir"`
+}
+
+// scriptFileTypes are the Apps Script file types by extension
+var scriptFileTypes = map[string]string{
+	".js":   "server_js",
+	".gs":   "server_js",
+	".html": "html",
+}
+
+// readScriptBundle reads an Apps Script project, either a bundle in the
+// import format or a clasp project directory, returning the id of the
+// script given by its .clasp.json
+func readScriptBundle(source string) (*scriptBundle, string, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, "", err
+	}
+	if !fi.IsDir() {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, "", err
+		}
+		var bundle scriptBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return nil, "", fmt.Errorf("parsing %s failed with error: %v", source, err)
+		}
+		return &bundle, "", nil
+	}
+
+	var config claspConfig
+	if data, err := os.ReadFile(filepath.Join(source, claspConfigName)); err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, "", fmt.Errorf("parsing %s failed with error: %v", claspConfigName, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	root := filepath.Join(source, config.RootDir)
+	bundle := &scriptBundle{}
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		ext := filepath.Ext(rel)
+		name := strings.TrimSuffix(rel, ext)
+		fileType, ok := scriptFileTypes[ext]
+		if rel == appsScriptManifestName+".json" {
+			fileType, ok = "json", true
+		}
+		if !ok {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		bundle.Files = append(bundle.Files, scriptFile{Name: name, Type: fileType, Source: string(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(bundle.Files, func(i, j int) bool { return bundle.Files[i].Name < bundle.Files[j].Name })
+	return bundle, config.ScriptId, nil
+}
+
+// pushAppsScript replaces the files of an Apps Script project with a local
+// project. The project is the one of its .clasp.json, or else the project
+// of the same name in the destination folder, created when there is none.
+// The id of the project is exposed as the 'scriptId' output.
+func pushAppsScript(svc *drive.Service, source string) {
+	bundle, scriptId, err := readScriptBundle(source)
+	if err != nil {
+		fatalf(fmt.Sprintf("reading Apps Script project %s failed with error: %v", source, err))
+	}
+	hasManifest := false
+	for _, f := range bundle.Files {
+		hasManifest = hasManifest || f.Type == "json" && f.Name == appsScriptManifestName
+	}
+	if !hasManifest {
+		invalidInput(fmt.Sprintf("Apps Script project %s has no %s.json manifest", source, appsScriptManifestName))
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding Apps Script project failed with error: %v", err))
+	}
+	media := googleapi.ContentType(appsScriptImportFormat)
+
+	var pushed *drive.File
+	if scriptId != "" {
+		fmt.Printf("Updating Apps Script project %s with %d file(s)\n", scriptId, len(bundle.Files))
+		pushed, err = svc.Files.Update(scriptId, &drive.File{}).Media(bytes.NewReader(data), media).Fields("id,name").SupportsAllDrives(allDrives).Do()
+	} else {
+		name := getInput(nameInput)
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(filepath.Clean(source)), ".json")
+		}
+		folderId := destinationFolderId(svc)
+		if existing := findDriveFileInFolder(svc, folderId, name); existing != nil && existing.MimeType == appsScriptMimeType {
+			fmt.Printf("Updating Apps Script project %s (%s) with %d file(s)\n", name, existing.Id, len(bundle.Files))
+			pushed, err = svc.Files.Update(existing.Id, &drive.File{}).Media(bytes.NewReader(data), media).Fields("id,name").SupportsAllDrives(allDrives).Do()
+		} else {
+			fmt.Printf("Creating Apps Script project %s with %d file(s)\n", name, len(bundle.Files))
+			f := &drive.File{Name: name, MimeType: appsScriptMimeType, Parents: []string{folderId}}
+			pushed, err = svc.Files.Create(f).Media(bytes.NewReader(data), media).Fields("id,name").SupportsAllDrives(allDrives).Do()
+		}
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("pushing Apps Script project %s failed with error: %v", source, err))
+	}
+	fmt.Printf("Pushed Apps Script project %s (%s)\n", pushed.Name, pushed.Id)
+	githubactions.SetOutput("scriptId", pushed.Id)
+}
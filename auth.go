@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jws"
+	"golang.org/x/oauth2/jwt"
+)
+
+// skewTolerantSource issues tokens for a service account. When Google
+// rejects the JWT because the clock of the runner is off, the JWT is issued
+// again with the clock of Google servers.
+type skewTolerantSource struct {
+	conf *jwt.Config
+	// skew is how far the local clock is ahead of the clock of Google
+	// servers, once it caused a JWT to be rejected
+	skew time.Duration
+}
+
+func isInvalidJWT(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && strings.Contains(string(retrieveErr.Body), "invalid_grant") && strings.Contains(string(retrieveErr.Body), "JWT")
+}
+
+func (s *skewTolerantSource) Token() (*oauth2.Token, error) {
+	if s.skew != 0 {
+		return s.issue()
+	}
+	token, err := s.conf.TokenSource(context.Background()).Token()
+	if err == nil || !isInvalidJWT(err) {
+		return token, err
+	}
+	skew, skewErr := clockSkew()
+	if skewErr != nil || skew < maxClockSkew && skew > -maxClockSkew {
+		return nil, err
+	}
+	githubactions.Warningf(fmt.Sprintf("the clock of the runner is off by %v, which makes Google reject the credentials. issuing them with the clock of Google servers instead, but please synchronize the clock of the runner, e.g. with NTP", skew.Round(time.Second)))
+	s.skew = skew
+	return s.issue()
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(data); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return x509.ParsePKCS1PrivateKey(data)
+}
+
+// issue exchanges a JWT issued and expiring according to the clock of Google
+// servers for a token
+func (s *skewTolerantSource) issue() (*oauth2.Token, error) {
+	key, err := parsePrivateKey(s.conf.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key failed with error: %v", err)
+	}
+	now := time.Now().Add(-s.skew)
+	claims := &jws.ClaimSet{
+		Iss:   s.conf.Email,
+		Scope: strings.Join(s.conf.Scopes, " "),
+		Aud:   s.conf.TokenURL,
+		Iat:   now.Unix(),
+		Exp:   now.Add(time.Hour).Unix(),
+		Sub:   s.conf.Subject,
+	}
+	header := &jws.Header{Algorithm: "RS256", Typ: "JWT", KeyID: s.conf.PrivateKeyID}
+	assertion, err := jws.Encode(header, claims, key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.PostForm(s.conf.TokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching token failed with error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetching token failed with error: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &oauth2.RetrieveError{Response: resp, Body: body}
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("parsing token failed with error: %v", err)
+	}
+	token := &oauth2.Token{AccessToken: res.AccessToken, TokenType: res.TokenType}
+	if res.ExpiresIn > 0 {
+		// expires_in is relative, so the local clock can be used
+		token.Expiry = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
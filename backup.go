@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	copyFromFolderIdInput = "copyFromFolderId"
+
+	backupSourceProperty  = "gdriveUploadSource"
+	backupVersionProperty = "gdriveUploadSourceVersion"
+)
+
+// backupFolder copies the subtree of a folder into the destination folder
+// server-side, without downloading anything. Copies record the id and
+// version of their source, so that files unchanged since the last backup
+// are skipped and the copies of changed files are replaced.
+func backupFolder(svc *drive.Service, sourceId string, folderId string) {
+	fmt.Printf("Copying folder %s to %s\n", sourceId, folderId)
+	folders := map[string]string{"": folderId}
+	// copies are the files of the destination folders by the id of their
+	// source, listed when a folder is first copied into
+	copies := map[string]map[string]*drive.File{}
+	copiesIn := func(id string) map[string]*drive.File {
+		if c, ok := copies[id]; ok {
+			return c
+		}
+		c := map[string]*drive.File{}
+		children, err := listChildren(svc, id, "id,name,appProperties")
+		if err != nil {
+			fatalf(fmt.Sprintf("listing folder %s failed with error: %v", id, err))
+		}
+		for _, child := range children {
+			if source := child.AppProperties[backupSourceProperty]; source != "" {
+				c[source] = child
+			}
+		}
+		copies[id] = c
+		return c
+	}
+	var copied, unchanged int
+	err := walkTree(svc, sourceId, "id,name,mimeType,version,description", func(folderPath string, f *drive.File) {
+		parentId, ok := folders[folderPath]
+		if !ok {
+			// the folder failed to be created
+			return
+		}
+		p := remotePath(folderPath, f.Name)
+		op := operation{Action: actionCreate, Path: p, Folder: folderPath, Name: f.Name, Source: f.Id, ParentId: parentId, Description: f.Description}
+		if f.MimeType == folderMimeType {
+			id, err := createDriveDirectory(svc, parentId, f.Name)
+			if err != nil {
+				recordFailure(op, err)
+				return
+			}
+			folders[p] = id
+			return
+		}
+		version := strconv.FormatInt(f.Version, 10)
+		existing := copiesIn(parentId)[f.Id]
+		if existing != nil && existing.AppProperties[backupVersionProperty] == version {
+			unchanged++
+			return
+		}
+		c, err := svc.Files.Copy(f.Id, &drive.File{
+			Name:          f.Name,
+			Description:   f.Description,
+			Parents:       []string{parentId},
+			AppProperties: map[string]string{backupSourceProperty: f.Id, backupVersionProperty: version},
+		}).Fields("id,name,size").SupportsAllDrives(allDrives).Do()
+		if err != nil {
+			recordFailure(op, fmt.Errorf("copying file %s failed with error: %w", f.Id, err))
+			return
+		}
+		fmt.Printf("Copied %s to %s (%s)\n", p, c.Name, c.Id)
+		copied++
+		op.Size = c.Size
+		runManifest.add(op, c.Id)
+		if existing != nil {
+			if _, err := svc.Files.Update(existing.Id, &drive.File{Trashed: true}).Fields("id").SupportsAllDrives(allDrives).Do(); err != nil {
+				githubactions.Warningf(fmt.Sprintf("trashing the previous copy of %s (%s) failed with error: %v", p, existing.Id, err))
+			}
+		}
+	})
+	if err != nil {
+		fatalf(err.Error())
+	}
+	fmt.Printf("Copied %d file(s), %d unchanged since the last backup\n", copied, unchanged)
+}
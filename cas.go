@@ -0,0 +1,63 @@
+package main
+
+import "fmt"
+
+const (
+	layoutInput            = "layout"
+	layoutCas              = "cas"
+	defaultCasManifestName = "manifest.json"
+
+	actionKeep = "keep"
+)
+
+// addContentAddressed plans storing a file under a path derived from its
+// SHA-256, e.g. sha256/ab/cd/abcd..., unless that content is already stored
+// or planned to be stored by another file of the run.
+// logicalPath is the name the file is known by in the manifest.
+func (p *planner) addContentAddressed(file string, logicalPath string, mimeType string, description string) {
+	if file == stdinFilename {
+		invalidInput(fmt.Sprintf("layout '%s' does not support uploading from stdin", layoutCas))
+	}
+	h, err := hashContent(file)
+	if err != nil {
+		fatalf(fmt.Sprintf("hashing file %s failed with error: %v", file, err))
+	}
+	sum := h.Sha256
+	dirs := []string{"sha256", sum[0:2], sum[2:4]}
+	folder, parentId := p.resolveFolder(dirs)
+	op := operation{
+		Action:      actionCreate,
+		Path:        remotePath(folder, sum),
+		Folder:      folder,
+		Name:        sum,
+		LogicalPath: logicalPath,
+		Source:      file,
+		ParentId:    parentId,
+		MimeType:    mimeType,
+		Description: description,
+		Size:        h.Size,
+		Sha256:      sum,
+		Md5:         h.Md5,
+		Reason:      "content is not stored yet",
+		Upload:      transformedCopy(file),
+		Scan:        scanResults[file],
+	}
+	op.recordMode()
+	if first, ok := p.stored[sum]; ok {
+		fmt.Printf("Content of %s is the same as %s\n", file, first)
+		op.Action = actionKeep
+		op.Target = first
+		op.Reason = fmt.Sprintf("same content as %s planned by this run", first)
+	} else if parentId != "" {
+		if existing := p.findFileInFolder(parentId, sum); existing != nil {
+			fmt.Printf("Content of %s is already stored as %s (%s)\n", file, op.Path, existing.Id)
+			op.Action = actionKeep
+			op.FileId = existing.Id
+			op.Reason = "content is already stored"
+		}
+	}
+	if op.Action == actionCreate {
+		p.stored[sum] = file
+	}
+	p.plan.Operations = append(p.plan.Operations, op)
+}
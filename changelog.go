@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	changelogInput    = "changelog"
+	googleDocMimeType = "application/vnd.google-apps.document"
+	maxChangelogFiles = 20
+)
+
+// changelogLine describes the files uploaded by the run
+func changelogLine() string {
+	var names []string
+	for i, f := range runManifest.Files {
+		if i == maxChangelogFiles {
+			names = append(names, fmt.Sprintf("and %d more", len(runManifest.Files)-maxChangelogFiles))
+			break
+		}
+		names = append(names, f.Name)
+	}
+	sha := os.Getenv("GITHUB_SHA")
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	line := time.Now().UTC().Format(time.RFC3339)
+	if sha != "" {
+		line += " " + sha
+	}
+	if run := os.Getenv("GITHUB_RUN_NUMBER"); run != "" {
+		line += " run " + run
+	}
+	return line + ": " + strings.Join(names, ", ")
+}
+
+// updateChangelog appends a line describing the run to the changelog in the
+// destination folder. The changelog is a text file, or a Google Doc if one
+// with the name exists.
+func updateChangelog(svc *drive.Service, pl *plan) {
+	if pl.Changelog == "" || len(runManifest.Files) == 0 {
+		return
+	}
+	op := operation{Action: actionCreate, Path: pl.Changelog, Name: pl.Changelog, Source: "changelog"}
+	existing := findDriveFileInFolder(svc, pl.FolderId, pl.Changelog)
+	var content string
+	mimeType := "text/plain"
+	if existing != nil {
+		op.Action = actionUpdate
+		if existing.MimeType == googleDocMimeType {
+			mimeType = ""
+		}
+		data, err := downloadDriveFile(svc, existing)
+		if err != nil {
+			recordFailure(op, fmt.Errorf("downloading changelog failed with error: %w", err))
+			return
+		}
+		content = strings.TrimPrefix(string(data), "\ufeff")
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+	}
+	content += changelogLine() + "\n"
+
+	tmp, err := os.CreateTemp(tempDir, "gdrive-upload-changelog-*.txt")
+	if err != nil {
+		recordFailure(op, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString(content)
+	tmp.Close()
+
+	fmt.Printf("Updating changelog %s\n", pl.Changelog)
+	if _, err := uploadToDrive(svc, tmp.Name(), pl.FolderId, existing, pl.Changelog, mimeType, "", nil); err != nil {
+		recordFailure(op, err)
+	}
+}
+
+// downloadDriveFile returns the content of a file, exported as plain text if
+// it is a Google Doc
+func downloadDriveFile(svc *drive.Service, f *drive.File) ([]byte, error) {
+	var resp *http.Response
+	var err error
+	if f.MimeType == googleDocMimeType {
+		resp, err = svc.Files.Export(f.Id, "text/plain").Download()
+	} else {
+		resp, err = svc.Files.Get(f.Id).SupportsAllDrives(allDrives).Download()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
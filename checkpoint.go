@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const batchSizeInput = "batchSize"
+
+// batchSize is the number of files after which the bookkeeping of the run is
+// flushed, or 0 to flush it at the end of the run only
+var batchSize int
+
+func parseBatchSize() {
+	v := getInput(batchSizeInput)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		invalidInput(fmt.Sprintf("input '%v' must be a number of files, got '%v'", batchSizeInput, v))
+	}
+	batchSize = n
+}
+
+// checkpoint writes the upload state, index, file registry and failures,
+// and uploads the manifest of the files uploaded so far, so that a crash or
+// cancellation loses at most one batch of bookkeeping
+func checkpoint(svc *drive.Service, pl *plan, files int) {
+	fmt.Printf("Checkpoint after %d file(s)\n", files)
+	writeUploadState()
+	writeIndex()
+	writeRegistry()
+	writeFailuresFile()
+	uploadManifest(svc, pl)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	checksumsNameInput = "checksumsName"
+	maxChecksumsWrites = 5
+)
+
+// checksumEntry is what is known about a file managed in the destination
+type checksumEntry struct {
+	FileId  string `json:"fileId"`
+	Sha256  string `json:"sha256"`
+	Md5     string `json:"md5,omitempty"`
+	Size    int64  `json:"size"`
+	RunId   string `json:"runId,omitempty"`
+	Updated string `json:"updated"`
+}
+
+// checksumDB is stored in the destination folder and maps the paths of the
+// managed files to their checksums, so that files can be compared without
+// querying Google Drive for each of them
+type checksumDB struct {
+	Files map[string]checksumEntry `json:"files"`
+}
+
+// readChecksums downloads the checksum database, returning the file it is
+// stored in, nil if there is none yet
+func readChecksums(svc *drive.Service, folderId string, name string) (*checksumDB, *drive.File, error) {
+	db := &checksumDB{Files: map[string]checksumEntry{}}
+	existing := findDriveFileInFolder(svc, folderId, name)
+	if existing == nil {
+		return db, nil, nil
+	}
+	data, err := downloadDriveFile(svc, existing)
+	if err != nil {
+		return nil, nil, fmt.Errorf("downloading %s failed with error: %w", name, err)
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, nil, fmt.Errorf("parsing %s failed with error: %w", name, err)
+	}
+	if db.Files == nil {
+		db.Files = map[string]checksumEntry{}
+	}
+	return db, existing, nil
+}
+
+// loadChecksums reads the checksum database the plan is compared with
+func loadChecksums(svc *drive.Service, folderId string, name string) *checksumDB {
+	db, _, err := readChecksums(svc, folderId, name)
+	if err != nil {
+		fatalf(fmt.Sprintf("reading checksum database failed with error: %v", err))
+	}
+	fmt.Printf("Loaded checksums of %d file(s) from %s\n", len(db.Files), name)
+	return db
+}
+
+// isUnchanged reports whether the database records the same content at the
+// target of an operation
+func (db *checksumDB) isUnchanged(op operation) bool {
+	if db == nil || op.Sha256 == "" {
+		return false
+	}
+	e, ok := db.Files[op.Path]
+	return ok && e.FileId != "" && e.Size == op.Size && e.Sha256 == op.Sha256
+}
+
+// updateChecksums records the files uploaded by the run in the checksum
+// database. Drive has no conditional updates, so the database is re-read and
+// the update retried when another run changed it in the meantime.
+func updateChecksums(svc *drive.Service, pl *plan) {
+	if pl.Checksums == "" || len(runManifest.Files) == 0 {
+		return
+	}
+	op := operation{Action: actionUpdate, Path: pl.Checksums, Name: pl.Checksums, Source: "checksums"}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for attempt := 1; ; attempt++ {
+		db, existing, err := readChecksums(svc, pl.FolderId, pl.Checksums)
+		if err != nil {
+			recordFailure(op, err)
+			return
+		}
+		for _, f := range runManifest.Files {
+			if f.Sha256 == "" {
+				continue
+			}
+			db.Files[f.Path] = checksumEntry{FileId: f.FileId, Sha256: f.Sha256, Md5: f.Md5, Size: f.Size, RunId: os.Getenv("GITHUB_RUN_ID"), Updated: now}
+		}
+		data, err := json.MarshalIndent(db, "", "  ")
+		if err != nil {
+			fatalf(fmt.Sprintf("encoding checksum database failed with error: %v", err))
+		}
+		if existing != nil {
+			current, err := svc.Files.Get(existing.Id).Fields("id,version").SupportsAllDrives(allDrives).Do()
+			if err != nil {
+				recordFailure(op, fmt.Errorf("looking up %s failed with error: %w", pl.Checksums, err))
+				return
+			}
+			if current.Version != existing.Version {
+				if attempt == maxChecksumsWrites {
+					recordFailure(op, fmt.Errorf("%s kept changing while it was updated", pl.Checksums))
+					return
+				}
+				fmt.Printf("%s was changed by another run, merging again\n", pl.Checksums)
+				continue
+			}
+		}
+		tmp, err := os.CreateTemp(tempDir, "gdrive-upload-checksums-*.json")
+		if err != nil {
+			recordFailure(op, err)
+			return
+		}
+		tmp.Write(data)
+		tmp.Close()
+		fmt.Printf("Updating checksums of %d file(s) in %s\n", len(runManifest.Files), pl.Checksums)
+		_, err = uploadToDrive(svc, tmp.Name(), pl.FolderId, existing, pl.Checksums, "application/json", "", nil)
+		os.Remove(tmp.Name())
+		if err != nil {
+			recordFailure(op, err)
+		}
+		return
+	}
+}
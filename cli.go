@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// stdinFilename is the filename used to stream stdin into a Drive file
+const stdinFilename = "-"
+
+// cliInputs lists the action inputs that can be passed as flags in CLI mode
+var cliInputs = []struct {
+	name  string
+	usage string
+}{
+	{credentialsInput, "the service account credentials encoded in base64"},
+	{folderIdInput, "the Id of the parent folder you want to upload the file in"},
+	{folderPropertyInput, "appProperties marker (key=value) of the parent folder, instead of folderId"},
+	{nameInput, "what you want the file to be called in Google Drive"},
+	{overwriteInput, "if you want to overwrite an existing file in Google Drive"},
+	{mimeTypeInput, "file MimeType"},
+	{descriptionInput, "description of the uploaded files"},
+	{descriptionFromCommitInput, "use the triggering commit message as description"},
+	{useCompleteSourceName, "use the source filename as target name"},
+	{mirrorDirectoryStructure, "recreate the directory structure of the source file"},
+	{pathRewriteInput, "rules rewriting mirrored paths, one 'regex => replacement' per line"},
+	{appendSourceExtInput, "append the extension of the source file to a name without one"},
+	{groupByTopDirInput, "upload files to one folder per top-level matched directory"},
+	{namePrefixInput, "prefix to be added to target filename"},
+	{folderCreateBackoffInput, "maximum random delay before creating a folder, e.g. 3s"},
+	{layoutInput, "layout of the uploaded files: flat or cas"},
+	{manifestNameInput, "name of the manifest uploaded to the destination folder"},
+	{changelogInput, "name of a changelog in the destination folder to append the run to"},
+	{followSymlinksInput, "upload the files symlinks point to (default true)"},
+	{symlinksAsShortcutsInput, "upload symlinks to files of the run as Drive shortcuts when not following them"},
+	{recordPermissionsInput, "record the mode bits of uploaded files in their appProperties"},
+	{recordOwnershipInput, "also record the uid and gid of uploaded files"},
+	{compareContentInput, "compare the checksum of overwritten files and only update the metadata of unchanged ones"},
+	{appendOnlyFilesInput, "patterns of append-only files, uploaded again once they grew by minSizeIncrease"},
+	{minSizeIncreaseInput, "number of bytes append-only files must grow by to be uploaded again"},
+	{replicasInput, "ids of additional destination folders or shared drives, one per line"},
+	{replicaCopyInput, "copy files created in replicas from the primary destination instead of uploading them"},
+	{enforcePermissionsInput, "converge permissions to permissionsPolicy: true, false or audit"},
+	{permissionsPolicyInput, "JSON array of the permissions the destination and uploaded files should have"},
+	{sharedDriveMaintenanceInput, "members, pendingDeletion or move, to maintain the shared drive given by sharedDriveId instead of uploading"},
+	{sharedDriveIdInput, "id of the shared drive maintained by sharedDriveMaintenance"},
+	{ownershipReportInput, "report the files of the destination not owned by expectedOwner instead of uploading"},
+	{expectedOwnerInput, "email of the expected owner of the files (default: the service account)"},
+	{checksumsNameInput, "name of a checksum database in the destination folder to compare and record files with"},
+	{overwriteCheckInput, "fail overwriting files changed since they were looked up: version or content"},
+	{listConcurrencyInput, "number of folders listed at the same time when scanning remote trees"},
+	{requestsPer100SecondsInput, "Drive API requests per 100 seconds quota of the account to stay below"},
+	{requireRemotePathInput, "paths that must exist in the destination folder, one per line"},
+	{tempDirInput, "directory temporary files, e.g. transformed copies, are written to"},
+	{transformInput, "transforms applied to copies of matching files before upload, one 'pattern => transform' per line"},
+	{gzipPatternsInput, "patterns of files gzipped before upload, one per line"},
+	{pinToFolderInput, "id of a folder to keep shortcuts to the latest uploads in"},
+	{statusNameInput, "name of a status file in the destination folder tracking the state of the run"},
+	{waitForVisibilityInput, "maximum time to wait for the uploaded files to be listed, e.g. 60s"},
+	{logGroupsInput, "fold the logs of each file or directory into a group: file or directory"},
+	{metadataSidecarsInput, "also upload a <name>.meta.json sidecar with the metadata of each file"},
+	{skipIfExistsInput, "skip files whose name already exists in the destination, without hashing"},
+	{fileRegistryInput, "path of a registry of the ids of created files, to overwrite them under the drive.file scope"},
+	{sharedDriveSupportInput, "auto, on or off: whether requests support and include shared drives"},
+	{batchSizeInput, "number of files after which the state, index, failures and manifest are written"},
+	{rootDigestInput, "compute a merkle root digest of the paths and hashes of the uploaded files"},
+	{stabilityWindowInput, "time, e.g. 2s, files must be unchanged for before they are uploaded"},
+	{excludeMimeTypesInput, "MIME types, e.g. application/x-executable or video/*, of files to skip, detected from their content"},
+	{maxTotalSizeInput, "maximum total size, e.g. 500MB, of the matched files"},
+	{maxTotalSizePolicyInput, "fail or trim: what to do when the files are larger than maxTotalSize"},
+	{usageReportInput, "report the total size and file count of the destination folder after the run"},
+	{usageBudgetInput, "size, e.g. 10GB, of the destination folder above which usageReport warns"},
+	{conflictExpressionInput, "template returning overwrite, skip, create or fail for files whose name exists"},
+	{copyFromFolderIdInput, "id of a folder to copy server-side into the destination instead of uploading"},
+	{restoreManifestInput, "manifest, a local file or the id of a file in Google Drive, whose files are downloaded instead of uploading"},
+	{restoreDirInput, "directory files are restored to, the working directory by default"},
+	{revisionMarkerInput, "name template labeling the revision of overwritten files, e.g. run {{.RunNumber}} at {{.Sha}}"},
+	{revisionMarkerFieldInput, "property or description: where the revision marker is stored"},
+	{respectGitignoreInput, "skip the matched files ignored by the .gitignore files of the repository"},
+	{routeByExtensionInput, "folders files are routed to by extension, one '.ext => folderId' or 'default => folderId' per line"},
+	{idempotencyKeyInput, "key of the run, a completed run with the same key makes the run a no-op"},
+	{fileTimeoutInput, "time budget of the upload of a single file, e.g. 10m, after which it is retried once after the others"},
+	{treeSnapshotInput, "path to write a JSON snapshot of the destination tree to after the run"},
+	{caBundleInput, "path or content of PEM certificates to trust on top of the system ones"},
+	{insecureSkipVerifyInput, "do not verify the certificates of Google servers (discouraged)"},
+	{warmStartInput, "list the destination once before planning instead of looking up each file"},
+	{longPathsInput, "how folders and names exceeding the limits of Drive are handled: fail or truncate"},
+	{virusScanInput, "virus scanner run over the files before uploading: clamav or a command prefixed with sh:"},
+	{virusScanActionInput, "what to do with infected files: fail or skip"},
+	{resumableThresholdInput, "size from which files are sent with a resumable upload, e.g. 5MB"},
+	{uploadChunkSizeInput, "size of the chunks of resumable uploads, e.g. 64MiB"},
+	{checkFreeSpaceInput, "check that every destination has room for its files before uploading"},
+	{appsScriptInput, "clasp project directory or Apps Script bundle deployed instead of uploading files"},
+	{recordProvenanceInput, "record the repository, workflow and branch of uploaded files"},
+	{cleanupInput, "trash the files uploaded with the given provenance, one repository=, workflow= or branch= filter per line"},
+	{retainForInput, "trash the files created longer ago than the given duration instead of uploading"},
+	{retentionHoldLabelsInput, "ids of the Drive labels protecting files from retainFor, one per line"},
+	{previewModeInput, "upload the preview of a pull request to a folder of its own, removed once the pull request is closed"},
+	{githubTokenInput, "token commenting the preview link on the pull request"},
+	{pipelineDepthInput, "number of files transformed and hashed ahead of planning, 0 to disable"},
+	{folderRequestsInboxInput, "folder missing folders are requested in instead of creating them"},
+	{inputsJsonInput, "JSON object carrying the inputs, used for the inputs not set explicitly"},
+	{actionInput, "upload, or validate to only check the config file and inputsJson"},
+	{templateFolderIdInput, "id of a template folder whose subfolders and files are copied into the destination before uploading"},
+	{templateFolderNameInput, "name template of a new folder of the destination the template is copied into and files are uploaded to"},
+	{waitForPreviewsInput, "maximum duration to wait for Drive to process the previews of uploaded videos, images and PDFs, e.g. 5m"},
+	{fileDatesInput, "path of a JSON file listing the createdTime and modifiedTime uploads of source files are backdated to"},
+	{resumeInput, "persist the work queue to queueFile, and resume the session it was left by if it exists"},
+	{queueFileInput, "path of the queue file used by resume, .gdrive-upload/queue.jsonl by default"},
+	{onMissingInput, "fail, or skip to skip the files that disappear between matching and uploading them"},
+	{namePatternInput, "regular expression matched against the source paths, whose named capture groups name templates can use as .Match"},
+	{onErrorInput, "fail, or warn to turn every failure into a warning and succeed, for best-effort publishing"},
+	{dryRunInput, "only plan the changes without applying them"},
+	{planFileInput, "path the plan of a dry run is written to"},
+	{applyPlanInput, "path of a previously generated plan to apply"},
+	{metricsFileInput, "path to write Prometheus metrics of the run to"},
+	{failuresFileInput, "path the list of failed files is persisted to"},
+	{retryFailedOnlyInput, "only upload the files that failed in the previous attempt"},
+	{skipUploadedInput, "skip files already uploaded by a previous attempt"},
+	{stateFileInput, "path the fingerprints of uploaded files are persisted to"},
+	{skipUnchangedInput, "skip files unchanged since they were last uploaded"},
+	{indexFileInput, "path the index of uploaded files is persisted to"},
+	{profileInput, "named profile from the config file"},
+	{configFileInput, "path of the config file defining profiles"},
+}
+
+// cliValues holds the inputs given on the command line in CLI mode
+var cliValues = map[string]*string{}
+
+// parseCLI parses the command line when the binary is run outside of the
+// Docker action, e.g. `pg_dump db | gdrive-upload -folderId <id> -name db.sql -`
+func parseCLI(args []string) {
+	fs := flag.NewFlagSet("gdrive-upload", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gdrive-upload [flags] <filename|->\n       gdrive-upload [flags] -exec <command>\n\n")
+		fmt.Fprintf(fs.Output(), "Inputs that are not given as flags are read from INPUT_<NAME> environment variables.\n\n")
+		fs.PrintDefaults()
+	}
+	for _, in := range cliInputs {
+		cliValues[in.name] = fs.String(in.name, "", in.usage)
+	}
+	fs.StringVar(&execCommand, "exec", "", "shell command whose output is streamed into a Drive file instead of a filename")
+	fs.Parse(args)
+
+	if fs.NArg() > 1 || execCommand != "" && fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(exitValidation)
+	}
+	if fs.NArg() == 1 {
+		filename := fs.Arg(0)
+		cliValues[filenameInput] = &filename
+	}
+	if execCommand != "" {
+		filename := stdinFilename
+		cliValues[filenameInput] = &filename
+		stdinReader = &commandOutput{command: execCommand}
+	}
+}
+
+// getInput returns the value of an input enforced by the upload policy of the
+// destination, or else the command line flag in CLI mode, the action input,
+// inputsJson, the selected profile and the defaults of the upload policy
+func getInput(name string) string {
+	if v, ok := policyEnforced[name]; ok {
+		return v
+	}
+	if v := getInputUnenforced(name); v != "" {
+		return v
+	}
+	return policyDefaults[name]
+}
+
+func getInputUnenforced(name string) string {
+	if v, ok := cliValues[name]; ok && *v != "" {
+		return *v
+	}
+	if v := githubactions.GetInput(name); v != "" {
+		return v
+	}
+	if v := jsonValues[name]; v != "" {
+		return v
+	}
+	return profileValues[name]
+}
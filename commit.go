@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	descriptionInput           = "description"
+	descriptionFromCommitInput = "descriptionFromCommit"
+	maxDescriptionLength       = 1000
+)
+
+var (
+	commitMessageOnce  sync.Once
+	commitMessageValue string
+)
+
+// commitMessage returns the message of the commit that triggered the
+// workflow, from the event payload or, when the event has none, from git
+func commitMessage() string {
+	commitMessageOnce.Do(func() {
+		commitMessageValue = readCommitMessage()
+	})
+	return commitMessageValue
+}
+
+func readCommitMessage() string {
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var event struct {
+				HeadCommit struct {
+					Message string `json:"message"`
+				} `json:"head_commit"`
+			}
+			if json.Unmarshal(data, &event) == nil && event.HeadCommit.Message != "" {
+				return strings.TrimSpace(event.HeadCommit.Message)
+			}
+		}
+	}
+	sha := os.Getenv("GITHUB_SHA")
+	if sha == "" {
+		sha = "HEAD"
+	}
+	out, err := exec.Command("git", "log", "-1", "--format=%B", sha).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
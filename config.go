@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	configFileInput   = "configFile"
+	profileInput      = "profile"
+	defaultConfigFile = ".github/gdrive-upload.json"
+)
+
+// config is the repository config file shared by the workflows of a repo
+type config struct {
+	Profiles map[string]map[string]json.RawMessage `json:"profiles"`
+}
+
+// profileValues holds the inputs of the selected profile
+var profileValues = map[string]string{}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s failed with error: %v", path, err)
+	}
+	return &c, nil
+}
+
+// loadProfile reads the named profile from the config file and uses its
+// values as defaults for inputs that are not set explicitly
+func loadProfile() {
+	profile := getInput(profileInput)
+	if profile == "" {
+		return
+	}
+	path := getInput(configFileInput)
+	if path == "" {
+		path = defaultConfigFile
+	}
+	c, err := loadConfig(path)
+	if err != nil {
+		fatalf(fmt.Sprintf("loading config file failed with error: %v", err))
+	}
+	values, ok := c.Profiles[profile]
+	if !ok {
+		var names []string
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		invalidInput(fmt.Sprintf("profile '%s' not found in %s. available profiles: %s", profile, path, strings.Join(names, ", ")))
+	}
+	for k, raw := range values {
+		if k == credentialsInput || k == profileInput || k == configFileInput {
+			invalidInput(fmt.Sprintf("input '%s' cannot be set in a profile", k))
+		}
+		// accept plain JSON values (booleans, numbers) as well as strings
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		profileValues[k] = strings.TrimSpace(s)
+	}
+	fmt.Printf("Using profile %s from %s\n", profile, path)
+}
+
+// resolvedInputs are the values inputs were resolved to by the run, i.e.
+// their defaults or rendered templates, overriding the values they were set to
+var resolvedInputs = map[string]string{}
+
+// secretInputs are redacted from the resolved configuration
+var secretInputs = map[string]bool{
+	credentialsInput: true,
+	githubTokenInput: true,
+}
+
+// resolvedConfig returns the effective value of every input set or resolved
+// by the run, with the secrets redacted, so that a past run can be
+// reproduced. Values set by inputsJson are redacted the same way, and the
+// document itself is left out, as its values are resolved into their inputs.
+func resolvedConfig() map[string]string {
+	config := map[string]string{}
+	names := []string{filenameInput}
+	for _, in := range cliInputs {
+		if in.name != inputsJsonInput {
+			names = append(names, in.name)
+		}
+	}
+	for _, name := range names {
+		v := getInput(name)
+		if r, ok := resolvedInputs[name]; ok {
+			v = r
+		}
+		if v == "" {
+			continue
+		}
+		if secretInputs[name] {
+			v = "***"
+		}
+		config[name] = v
+	}
+	return config
+}
+
+// outputConfig exposes the resolved configuration as the 'config' output
+func outputConfig() {
+	data, err := json.Marshal(resolvedConfig())
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding configuration failed with error: %v", err))
+	}
+	githubactions.SetOutput("config", string(data))
+}
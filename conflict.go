@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	conflictExpressionInput = "conflictExpression"
+
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictCreate    = "create"
+	conflictFail      = "fail"
+)
+
+// conflictFile is what is known about one side of a conflict
+type conflictFile struct {
+	Id      string
+	Name    string
+	Path    string
+	Size    int64
+	Sha256  string
+	Md5     string
+	ModTime time.Time
+	Version int64
+}
+
+// conflictData is the data available to the conflict expression: the local
+// file and the file of the same name in Google Drive
+type conflictData struct {
+	Local  conflictFile
+	Remote conflictFile
+}
+
+// conflictTemplate is nil unless conflictExpression is set
+var conflictTemplate *template.Template
+
+func parseConflictExpression() {
+	value := getInput(conflictExpressionInput)
+	if value == "" {
+		return
+	}
+	t, err := template.New(conflictExpressionInput).Funcs(nameFuncs).Option("missingkey=error").Parse(value)
+	if err != nil {
+		fatalf(fmt.Sprintf("parsing template of input '%s' failed with error: %v", conflictExpressionInput, err))
+	}
+	conflictTemplate = t
+}
+
+// resolveConflict evaluates the conflict expression for a file whose name
+// exists in Google Drive and returns the action to take
+func resolveConflict(op operation, existing *drive.File) string {
+	d := conflictData{
+		Local: conflictFile{Name: op.Name, Path: op.Source, Size: op.Size, Sha256: op.Sha256, Md5: op.Md5},
+		Remote: conflictFile{
+			Id:      existing.Id,
+			Name:    existing.Name,
+			Path:    op.Path,
+			Size:    existing.Size,
+			Md5:     existing.Md5Checksum,
+			Version: existing.Version,
+		},
+	}
+	if op.Source != stdinFilename {
+		if fi, err := os.Stat(op.Source); err == nil {
+			d.Local.ModTime = fi.ModTime()
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, existing.ModifiedTime); err == nil {
+		d.Remote.ModTime = t
+	}
+	var b bytes.Buffer
+	if err := conflictTemplate.Execute(&b, d); err != nil {
+		fatalf(fmt.Sprintf("executing template of input '%s' for %s failed with error: %v", conflictExpressionInput, op.Source, err))
+	}
+	action := strings.TrimSpace(b.String())
+	switch action {
+	case conflictOverwrite, conflictSkip, conflictCreate, conflictFail:
+		return action
+	}
+	invalidInput(fmt.Sprintf("input '%s' returned '%s' for %s, must be one of overwrite, skip, create or fail", conflictExpressionInput, action, op.Source))
+	return ""
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const fileDatesInput = "fileDates"
+
+// fileDate is the original creation and modification time of a source file
+type fileDate struct {
+	Source       string `json:"source"`
+	CreatedTime  string `json:"createdTime,omitempty"`
+	ModifiedTime string `json:"modifiedTime,omitempty"`
+}
+
+// fileDates are the dates files are backdated to, by source path
+var fileDates map[string]fileDate
+
+// loadFileDates reads the JSON file given by the fileDates input, a list of
+// source paths with the times their uploads are backdated to
+func loadFileDates() {
+	path := getInput(fileDatesInput)
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		invalidInput(fmt.Sprintf("reading input '%v' failed with error: %v", fileDatesInput, err))
+	}
+	var dates []fileDate
+	if err := json.Unmarshal(data, &dates); err != nil {
+		invalidInput(fmt.Sprintf("parsing %s of input '%v' failed with error: %v", path, fileDatesInput, describeJsonError(data, err)))
+	}
+	fileDates = map[string]fileDate{}
+	for i, d := range dates {
+		if d.Source == "" {
+			invalidInput(fmt.Sprintf("entry %d of %s has no source", i, path))
+		}
+		for _, t := range []*string{&d.CreatedTime, &d.ModifiedTime} {
+			if *t == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, *t)
+			if err != nil {
+				invalidInput(fmt.Sprintf("invalid time for %s in %s, must be RFC 3339: %v", d.Source, path, err))
+			}
+			*t = parsed.UTC().Format(time.RFC3339)
+		}
+		fileDates[filepath.Clean(d.Source)] = d
+	}
+	fmt.Printf("Loaded the dates of %d file(s) from %s\n", len(fileDates), path)
+}
+
+// recordDates sets the times the file of an operation is backdated to
+func (op *operation) recordDates() {
+	if d, ok := fileDates[filepath.Clean(op.Source)]; ok {
+		op.CreatedTime, op.ModifiedTime = d.CreatedTime, d.ModifiedTime
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	compareContentInput  = "compareContent"
+	appendOnlyFilesInput = "appendOnlyFiles"
+	minSizeIncreaseInput = "minSizeIncrease"
+	skipIfExistsInput    = "skipIfExists"
+
+	actionSkip           = "skip"
+	actionUpdateMetadata = "updateMetadata"
+)
+
+var compareContent bool
+
+// skipIfExists skips files whose name exists in the destination without
+// looking at their content
+var skipIfExists bool
+
+// appendOnlyPatterns match the source paths of files only ever appended to,
+// e.g. logs, which are uploaded again once they grew by minSizeIncrease
+var appendOnlyPatterns []string
+
+var minSizeIncrease int64
+
+func parseTransferInputs() {
+	compareContent = getBoolInput(compareContentInput)
+	skipIfExists = getBoolInput(skipIfExistsInput)
+	for _, line := range strings.Split(getInput(appendOnlyFilesInput), "\n") {
+		if pattern := strings.TrimSpace(line); pattern != "" {
+			if _, err := path.Match(pattern, ""); err != nil {
+				invalidInput(fmt.Sprintf("invalid pattern '%v' in input '%v': %v", pattern, appendOnlyFilesInput, err))
+			}
+			appendOnlyPatterns = append(appendOnlyPatterns, pattern)
+		}
+	}
+	if v := getInput(minSizeIncreaseInput); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			invalidInput(fmt.Sprintf("input '%v' must be a number of bytes, got '%v'", minSizeIncreaseInput, v))
+		}
+		minSizeIncrease = n
+	}
+}
+
+func isAppendOnly(file string) bool {
+	if file == stdinFilename {
+		return false
+	}
+	for _, pattern := range appendOnlyPatterns {
+		if matchesPattern(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendOnlySkip returns why an append-only file that is already in Google
+// Drive is not uploaded again, or an empty string if it is
+func appendOnlySkip(op operation, existing *drive.File) string {
+	size := op.Size
+	if op.Sha256 == "" {
+		fi, err := os.Stat(op.content())
+		if err != nil {
+			return ""
+		}
+		size = fi.Size()
+	}
+	growth := size - existing.Size
+	switch {
+	case growth < 0:
+		// the file was truncated or rotated
+		return ""
+	case growth == 0:
+		return "append-only file did not grow"
+	case growth < minSizeIncrease:
+		return fmt.Sprintf("append-only file grew by %d byte(s), less than %s", growth, minSizeIncreaseInput)
+	}
+	return ""
+}
+
+// metadataChanged reports whether the metadata of an operation differs from
+// the file with the same content in Google Drive
+func metadataChanged(op operation, existing *drive.File) bool {
+	if op.Name != existing.Name || op.Description != "" && op.Description != existing.Description {
+		return true
+	}
+	for k, v := range op.appProperties() {
+		if existing.AppProperties[k] != v {
+			return true
+		}
+	}
+	return false
+}
+
+// skip records why a file is not transferred. fileId is the id of the file
+// already in Google Drive, if known.
+func (p *planner) skip(op operation, fileId string, reason string) {
+	fmt.Printf("%s: %s, skipping\n", op.Source, reason)
+	op.Action = actionSkip
+	op.FileId = fileId
+	op.Reason = reason
+	op.Precondition = nil
+	p.fileIds[op.Source] = fileId
+	p.plan.Operations = append(p.plan.Operations, op)
+}
+
+// updateMetadata updates the metadata of a file whose content is unchanged
+func updateMetadata(svc *drive.Service, op operation) (*drive.File, error) {
+	f := &drive.File{
+		Name:          op.Name,
+		Description:   op.Description,
+		AppProperties: op.appProperties(),
+		ModifiedTime:  op.ModifiedTime,
+	}
+	start := time.Now()
+	updated, err := svc.Files.Update(op.FileId, f).Fields("id,name,size").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return nil, fmt.Errorf("updating metadata failed with error: %w", err)
+	}
+	fmt.Printf("Updated metadata of %s (%s) in %s\n", updated.Name, updated.Id, formatDuration(time.Since(start)))
+	return updated, nil
+}
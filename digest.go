@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const rootDigestInput = "rootDigest"
+
+// computeRootDigest sets the merkle root of the paths and SHA-256 of the
+// files uploaded by the run as the 'rootDigest' output and in the manifest,
+// so that the whole set can be verified with a single comparison. The leaves
+// are SHA-256(path + "\x00" + hex SHA-256 of the content) in order of path,
+// each level hashes pairs of nodes, and an odd last node is carried up.
+func computeRootDigest() {
+	if !getBoolInput(rootDigestInput) {
+		return
+	}
+	files := runManifest.Files
+	for i, f := range files {
+		if f.Sha256 != "" {
+			continue
+		}
+		if f.Source == stdinFilename {
+			githubactions.Warningf(fmt.Sprintf("content uploaded from stdin as %s cannot be hashed, it is part of the root digest without its hash", f.Name))
+			continue
+		}
+		h, err := hashContent(f.Source)
+		if err != nil {
+			fatalf(fmt.Sprintf("hashing file %s failed with error: %v", f.Source, err))
+		}
+		files[i].Sha256 = h.Sha256
+	}
+	sorted := append([]manifestEntry{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	var level [][]byte
+	for _, f := range sorted {
+		sum := sha256.Sum256([]byte(f.Name + "\x00" + f.Sha256))
+		level = append(level, sum[:])
+	}
+	if len(level) == 0 {
+		sum := sha256.Sum256(nil)
+		level = append(level, sum[:])
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				break
+			}
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	runManifest.RootDigest = hex.EncodeToString(level[0])
+	fmt.Printf("Root digest of %d file(s): %s\n", len(files), runManifest.RootDigest)
+	githubactions.SetOutput("rootDigest", runManifest.RootDigest)
+}
@@ -0,0 +1,6 @@
+package main
+
+// the free space is not checked on windows
+func freeSpace(dir string) (uint64, bool) {
+	return 0, false
+}
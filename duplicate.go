@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+// isTransientError reports whether a request failed without an answer from
+// Drive, so that it may have succeeded anyway
+func isTransientError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// findCreatedFile returns the file an earlier attempt to create the file of
+// an operation created, despite failing, i.e. a file of the same name, size
+// and checksum created in the folder since the attempt started. Files
+// backdated by fileDates are matched whenever they were created, as their
+// createdTime is in the past.
+func findCreatedFile(svc *drive.Service, op operation, parentId string, since time.Time) *drive.File {
+	q := fmt.Sprintf("%s and '%s' in parents and trashed=false", nameQuery(op.Name), escapeQuery(parentId))
+	if op.CreatedTime == "" {
+		// allow for the clock of the runner being off
+		since = since.Add(-time.Minute)
+		q += fmt.Sprintf(" and createdTime >= '%s'", since.UTC().Format(time.RFC3339))
+	}
+	r, err := svc.Files.List().Fields("files(id,name,size,md5Checksum)").Q(q).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("looking for %s created by the failed attempt failed with error: %v", op.Path, err))
+		return nil
+	}
+	if len(r.Files) == 0 {
+		return nil
+	}
+	h := fileHash{Size: op.Size, Md5: op.Md5}
+	if h.Md5 == "" || op.Upload != "" {
+		if h, err = hashFile(op.content()); err != nil {
+			githubactions.Warningf(fmt.Sprintf("hashing %s failed with error: %v", op.content(), err))
+			return nil
+		}
+	}
+	for _, f := range r.Files {
+		if f.Size == h.Size && f.Md5Checksum == h.Md5 {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// execCommand is the command whose output is uploaded instead of stdin, set
+// with -exec in CLI mode
+var execCommand string
+
+// stdinReader is the content uploaded for the stdin filename
+var stdinReader io.Reader = os.Stdin
+
+// commandOutput streams the output of a shell command, started on the first
+// read so that nothing runs when the upload is not attempted. Reading fails
+// when the command exits with an error, so that the upload is aborted
+// instead of storing truncated output.
+type commandOutput struct {
+	command string
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+}
+
+func (c *commandOutput) Read(p []byte) (int, error) {
+	if c.cmd == nil {
+		if runtime.GOOS == "windows" {
+			c.cmd = exec.Command("cmd", "/C", c.command)
+		} else {
+			c.cmd = exec.Command("sh", "-c", c.command)
+		}
+		c.cmd.Stderr = os.Stderr
+		stdout, err := c.cmd.StdoutPipe()
+		if err != nil {
+			return 0, err
+		}
+		c.stdout = stdout
+		fmt.Printf("Running %s\n", c.command)
+		if err := c.cmd.Start(); err != nil {
+			return 0, fmt.Errorf("starting command '%s' failed with error: %w", c.command, err)
+		}
+	}
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("command '%s' failed with error: %w", c.command, werr)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// exit codes of CLI mode, the action always exits with 1 on failure
+const (
+	exitFailure    = 1
+	exitValidation = 2
+	exitAuth       = 3
+	exitQuota      = 4
+	exitPartial    = 5
+	exitNoMatch    = 6
+)
+
+const (
+	onErrorInput = "onError"
+	onErrorFail  = "fail"
+	onErrorWarn  = "warn"
+)
+
+// cliMode is set when the binary is run with arguments
+var cliMode bool
+
+// softFail is set when onError is warn, turning failures into warnings
+var softFail bool
+
+// quotaExceeded is set when a file failed to upload because of a quota
+var quotaExceeded bool
+
+func parseOnError() {
+	switch v := getInput(onErrorInput); v {
+	case "", onErrorFail:
+		softFail = false
+	case onErrorWarn:
+		softFail = true
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", onErrorInput, onErrorFail, onErrorWarn, v))
+	}
+}
+
+// fail logs an error and exits with code in CLI mode. When onError is warn,
+// it logs a warning and a summary instead, sets the 'failed' output and
+// exits successfully, so that best-effort publishing never blocks the job.
+func fail(code int, msg string) {
+	if softFail {
+		githubactions.Warningf(msg)
+		githubactions.SetOutput("failed", "true")
+		appendStepSummary(fmt.Sprintf("### Google Drive upload failed\n\nThe job continues, as `%s` is `%s`:\n\n```\n%s\n```\n\n", onErrorInput, onErrorWarn, msg))
+		os.Exit(0)
+	}
+	githubactions.Errorf(msg)
+	if !cliMode {
+		code = exitFailure
+	}
+	os.Exit(code)
+}
+
+// fatalf fails the run with a message
+func fatalf(msg string) {
+	fail(exitFailure, msg)
+}
+
+// invalidInput fails because of invalid or missing inputs
+func invalidInput(msg string) {
+	fail(exitValidation, msg)
+}
+
+// isAuthError reports whether Google rejected the credentials
+func isAuthError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return true
+	}
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 401
+}
+
+// isQuotaError reports whether Drive rejected a request for exceeding a
+// request or storage quota
+func isQuotaError(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == 429 {
+		return true
+	}
+	for _, e := range apiErr.Errors {
+		if strings.HasSuffix(e.Reason, "LimitExceeded") || strings.HasSuffix(e.Reason, "QuotaExceeded") || e.Reason == "quotaExceeded" {
+			return true
+		}
+	}
+	return false
+}
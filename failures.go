@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	failuresFileInput    = "failuresFile"
+	retryFailedOnlyInput = "retryFailedOnly"
+	defaultFailuresFile  = ".gdrive-upload/failures.json"
+)
+
+// failuresState is the failure list persisted between attempts of a run
+type failuresState struct {
+	RunId       string       `json:"runId"`
+	RunAttempt  string       `json:"runAttempt"`
+	FailedFiles []failedFile `json:"failedFiles"`
+}
+
+// failuresFile is the path the failure list is persisted to
+var failuresFile string
+
+// failedFile describes a file that could not be uploaded
+type failedFile struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Error  string `json:"error"`
+	Status int    `json:"status,omitempty"`
+}
+
+var failedFiles = []failedFile{}
+
+func recordFailure(op operation, err error) {
+	if softFail {
+		githubactions.Warningf(fmt.Sprintf("uploading %s failed: %v", op.Source, err))
+	} else {
+		githubactions.Errorf(fmt.Sprintf("uploading %s failed: %v", op.Source, err))
+	}
+	f := failedFile{Path: op.Source, Target: op.Path, Error: err.Error()}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		f.Status = apiErr.Code
+	}
+	if isQuotaError(err) {
+		quotaExceeded = true
+	}
+	failedFiles = append(failedFiles, f)
+}
+
+// outputFailures exposes the failed files as the 'failedFiles' and
+// 'hasFailures' outputs
+func outputFailures() {
+	data, err := json.Marshal(failedFiles)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding failed files failed with error: %v", err))
+	}
+	githubactions.SetOutput("failedFiles", string(data))
+	githubactions.SetOutput("hasFailures", fmt.Sprint(len(failedFiles) > 0))
+}
+
+func writeFailuresFile() {
+	if failuresFile == "" {
+		return
+	}
+	data, err := json.Marshal(failuresState{
+		RunId:       os.Getenv("GITHUB_RUN_ID"),
+		RunAttempt:  os.Getenv("GITHUB_RUN_ATTEMPT"),
+		FailedFiles: failedFiles,
+	})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(failuresFile), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(failuresFile, data, 0644)
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing failures to %s failed with error: %v", failuresFile, err))
+	}
+}
+
+// filterFailedOnly keeps the files that failed in a previous attempt of the
+// same run. All files are kept when there is no previous attempt.
+func filterFailedOnly(files []string) []string {
+	data, err := os.ReadFile(failuresFile)
+	if os.IsNotExist(err) {
+		fmt.Printf("No failures of a previous attempt found in %s, uploading all files\n", failuresFile)
+		return files
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading failures from %s failed with error: %v", failuresFile, err))
+	}
+	var state failuresState
+	if err := json.Unmarshal(data, &state); err != nil {
+		fatalf(fmt.Sprintf("parsing failures from %s failed with error: %v", failuresFile, err))
+	}
+	if state.RunId != os.Getenv("GITHUB_RUN_ID") {
+		fmt.Printf("Failures in %s are from another run (%s), uploading all files\n", failuresFile, state.RunId)
+		return files
+	}
+	failed := map[string]bool{}
+	for _, f := range state.FailedFiles {
+		failed[f.Path] = true
+	}
+	var retry []string
+	for _, file := range files {
+		if failed[file] {
+			retry = append(retry, file)
+		}
+	}
+	fmt.Printf("Retrying %d file(s) that failed in attempt %s\n", len(retry), state.RunAttempt)
+	return retry
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// formatBytes formats a size for logs and summaries in binary units, e.g.
+// 1.5 MiB, while JSON outputs and the manifest keep exact numbers of bytes
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration formats a duration for logs and summaries, to the
+// millisecond below a second and to the tenth of a second or the second
+// above, while JSON outputs keep exact numbers of seconds
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// appendStepSummary adds markdown to the job summary, when running in
+// GitHub Actions
+func appendStepSummary(markdown string) {
+	summaryFile := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryFile == "" {
+		return
+	}
+	f, err := os.OpenFile(summaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(markdown)
+		f.Close()
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing job summary failed with error: %v", err))
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const respectGitignoreInput = "respectGitignore"
+
+// ignoreRule is a pattern of a .gitignore file
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore matches paths against the .gitignore files of a repository and
+// its .git/info/exclude, with the semantics of git
+type gitignore struct {
+	root    string
+	exclude []ignoreRule
+	// rules are the rules of the .gitignore file of each directory, relative
+	// to the root, loaded when first needed
+	rules map[string][]ignoreRule
+}
+
+// repositoryRoot returns the closest directory above the working directory
+// with a .git, or the working directory
+func repositoryRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return wd
+		}
+	}
+}
+
+func newGitignore(root string) *gitignore {
+	return &gitignore{
+		root:    root,
+		exclude: readIgnoreRules(filepath.Join(root, ".git", "info", "exclude")),
+		rules:   map[string][]ignoreRule{},
+	}
+}
+
+func readIgnoreRules(file string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\!") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// patterns with a slash are relative to the directory of the .gitignore,
+	// others match a name at any level
+	prefix := "(.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = ""
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	re, err := regexp.Compile("^" + prefix + ignoreGlobToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// ignoreGlobToRegexp translates a gitignore glob, where ** matches across
+// directories, to a regular expression
+func ignoreGlobToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+func (g *gitignore) rulesIn(dir string) []ignoreRule {
+	rules, ok := g.rules[dir]
+	if !ok {
+		rules = readIgnoreRules(filepath.Join(g.root, filepath.FromSlash(dir), ".gitignore"))
+		g.rules[dir] = rules
+	}
+	return rules
+}
+
+// match reports whether the last rule matching a path, relative to the
+// root, ignores it. The .gitignore files of deeper directories take
+// precedence.
+func (g *gitignore) match(p string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []ignoreRule, rel string) {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	apply(g.exclude, p)
+	dir := ""
+	for {
+		apply(g.rulesIn(dir), strings.TrimPrefix(p, dir+"/"))
+		next := strings.Index(strings.TrimPrefix(p, dir+"/"), "/")
+		if next < 0 {
+			return ignored
+		}
+		if dir == "" {
+			dir = p[:next]
+		} else {
+			dir = p[:len(dir)+1+next]
+		}
+	}
+}
+
+// ignored reports whether a path relative to the root is ignored. Like git,
+// nothing in an ignored directory can be included again.
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if parts[i] == ".git" {
+			return true
+		}
+		if g.match(strings.Join(parts[:i+1], "/"), isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterGitignored drops the files ignored by the .gitignore files of the
+// repository
+func filterGitignored(files []string) []string {
+	g := newGitignore(repositoryRoot())
+	var kept []string
+	for _, file := range files {
+		if file == stdinFilename {
+			kept = append(kept, file)
+			continue
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			kept = append(kept, file)
+			continue
+		}
+		rel, err := filepath.Rel(g.root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			kept = append(kept, file)
+			continue
+		}
+		isDir := false
+		if fi, err := os.Lstat(file); err == nil {
+			isDir = fi.IsDir()
+		}
+		if g.ignored(path.Clean(filepath.ToSlash(rel)), isDir) {
+			fmt.Printf("%s: ignored by .gitignore, skipping\n", file)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore": `# comment
+*.log
+!keep.log
+/build
+docs/*.tmp
+**/cache/
+node_modules/
+!node_modules/keep.js
+\#notes
+trailing   
+`,
+		"sub/.gitignore":      "!*.log\nsecret.txt\n/local\n",
+		".git/info/exclude":   "*.swp\n",
+		"deep/a/b/.gitignore": "*.bin\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := newGitignore(root)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"src/main.go", false, false},
+		{"a.log", false, true},
+		{"x/y/a.log", false, true},
+		{"keep.log", false, false},
+		{"x/keep.log", false, false},
+		{"build/app", false, true},
+		{"x/build/app", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/x/a.tmp", false, false},
+		{"a/b/cache/x", false, true},
+		{"cache", false, false},
+		{"cache", true, true},
+		{"node_modules/pkg/index.js", false, true},
+		{"node_modules/keep.js", false, true},
+		{"#notes", false, true},
+		{"trailing", false, true},
+		{"x.swp", false, true},
+		{".git/config", false, true},
+		{"sub/debug.log", false, false},
+		{"sub/secret.txt", false, true},
+		{"secret.txt", false, false},
+		{"sub/local", false, true},
+		{"sub/x/local", false, false},
+		{"deep/a/b/c/x.bin", false, true},
+		{"deep/x.bin", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := g.ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "a/main.go", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**", "a/x/y", true},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"[abc].txt", "b.txt", true},
+		{"[!abc].txt", "b.txt", false},
+		{"[!abc].txt", "d.txt", true},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{"a+b(c)", "a+b(c)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			r, ok := parseIgnoreRule("/" + tt.glob)
+			if !ok {
+				t.Fatalf("parseIgnoreRule(%q) failed", tt.glob)
+			}
+			if got := r.re.MatchString(tt.path); got != tt.want {
+				t.Errorf("%q matching %q = %v, want %v", tt.glob, tt.path, got, tt.want)
+			}
+		})
+	}
+}
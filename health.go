@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
+)
+
+// maxClockSkew is how far the clock of the runner may be off before tokens
+// signed with it are likely rejected
+const maxClockSkew = time.Minute
+
+// checkConnection verifies that Google Drive can be reached with the
+// credentials before anything is done, and prints diagnostics of the common
+// causes of failures otherwise
+func checkConnection(svc *drive.Service) {
+	about, err := svc.About.Get().Fields("user(emailAddress)").Do()
+	if err == nil {
+		fmt.Printf("Connected to Google Drive as %s\n", about.User.EmailAddress)
+		return
+	}
+	fmt.Println("Connecting to Google Drive failed, diagnosing the connection:")
+	for _, d := range diagnoseConnection(err) {
+		fmt.Printf("  %s\n", d)
+	}
+	msg := fmt.Sprintf("connecting to Google Drive failed with error: %v", err)
+	if isAuthError(err) {
+		fail(exitAuth, msg)
+	}
+	fatalf(msg)
+}
+
+func diagnoseConnection(err error) []string {
+	var diagnostics []string
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && strings.Contains(string(retrieveErr.Body), "invalid_grant") {
+		diagnostics = append(diagnostics, "the credentials were rejected: check that the service account and its key still exist, and that the clock of the runner is right")
+	}
+	for _, host := range []string{"oauth2.googleapis.com", "www.googleapis.com"} {
+		if _, err := net.LookupHost(host); err != nil {
+			diagnostics = append(diagnostics, fmt.Sprintf("resolving %s failed: %v. check the DNS configuration of the runner", host, err))
+		}
+	}
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		if v := os.Getenv(env); v != "" {
+			if u, err := url.Parse(v); err == nil && u.User != nil {
+				u.User = url.User("***")
+				v = u.String()
+			}
+			diagnostics = append(diagnostics, fmt.Sprintf("%s is set to %s, check that the proxy allows connections to *.googleapis.com", env, v))
+		}
+	}
+	if skew, err := clockSkew(); err != nil {
+		diagnostics = append(diagnostics, fmt.Sprintf("connecting to www.googleapis.com failed: %v", err))
+	} else if skew > maxClockSkew || skew < -maxClockSkew {
+		diagnostics = append(diagnostics, fmt.Sprintf("the clock of the runner is off by %v, so Google rejects the tokens signed with it. synchronize the clock, e.g. with NTP", skew.Round(time.Second)))
+	} else {
+		diagnostics = append(diagnostics, fmt.Sprintf("the clock of the runner is off by %v, which is fine", skew.Round(time.Second)))
+	}
+	return diagnostics
+}
+
+// clockSkew returns how far the local clock is ahead of the clock of Google
+// servers, from the Date header of a response
+func clockSkew() (time.Duration, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := client.Head("https://www.googleapis.com/")
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	server, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("parsing the Date header failed with error: %v", err)
+	}
+	// the Date header has a resolution of one second, compare it with the
+	// middle of the request
+	local := start.Add(time.Since(start) / 2)
+	return local.Sub(server), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	idempotencyKeyInput = "idempotencyKey"
+	// completedRunsProperty is the app property of the destination folder
+	// listing the digests of the keys of the last completed runs
+	completedRunsProperty = "gdriveUploadCompletedRuns"
+	maxCompletedRuns      = 7
+)
+
+// idempotencyKey returns the key of the run, or an empty string when runs
+// are not checked for having completed already. Other steps and matrix jobs
+// of a workflow run may upload to the same folder, so there is no default.
+func idempotencyKey() string {
+	return getInput(idempotencyKeyInput)
+}
+
+// idempotencyDigest is short enough for the keys of maxCompletedRuns runs to
+// fit in the 124 bytes of an app property
+func idempotencyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+func completedRuns(svc *drive.Service, folderId string) ([]string, error) {
+	f, err := svc.Files.Get(folderId).Fields("appProperties").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(f.AppProperties[completedRunsProperty]), nil
+}
+
+// alreadyCompleted reports whether a run with the same idempotency key
+// completed publishing to the destination folder, e.g. when a workflow is
+// delivered twice
+func alreadyCompleted(svc *drive.Service, folderId string) bool {
+	key := idempotencyKey()
+	if key == "" {
+		return false
+	}
+	runs, err := completedRuns(svc, folderId)
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("looking up the completed runs of folder %s failed with error: %v", folderId, err))
+		return false
+	}
+	for _, run := range runs {
+		if run == idempotencyDigest(key) {
+			fmt.Printf("A run with idempotency key %s already completed publishing to folder %s, nothing to do\n", key, folderId)
+			return true
+		}
+	}
+	return false
+}
+
+// recordCompleted adds the idempotency key of a run without failures to the
+// last completed runs of the destination folder
+func recordCompleted(svc *drive.Service, folderId string) {
+	key := idempotencyKey()
+	if key == "" || len(failedFiles) > 0 {
+		return
+	}
+	runs, err := completedRuns(svc, folderId)
+	if err == nil {
+		runs = append(runs, idempotencyDigest(key))
+		if len(runs) > maxCompletedRuns {
+			runs = runs[len(runs)-maxCompletedRuns:]
+		}
+		// drop the oldest runs until the property fits
+		for len(runs) > 1 && len(completedRunsProperty)+len(strings.Join(runs, " ")) > maxPropertyBytes {
+			runs = runs[1:]
+		}
+		_, err = svc.Files.Update(folderId, &drive.File{AppProperties: map[string]string{completedRunsProperty: strings.Join(runs, " ")}}).Fields("id").SupportsAllDrives(allDrives).Do()
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("recording the completed run in folder %s failed with error: %v", folderId, err))
+	}
+}
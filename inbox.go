@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const folderRequestsInboxInput = "folderRequestsInbox"
+
+// folderRequest is a folder the run needs but is not allowed to create
+type folderRequest struct {
+	DestinationId string `json:"destinationId"`
+	Path          string `json:"path"`
+	Name          string `json:"name"`
+	// ParentId is the id of the existing folder to create it in, empty when
+	// the parent is requested too
+	ParentId string `json:"parentId,omitempty"`
+}
+
+// folderRequests is the request manifest uploaded to the inbox folder
+type folderRequests struct {
+	Repository string          `json:"repository,omitempty"`
+	Workflow   string          `json:"workflow,omitempty"`
+	RunId      string          `json:"runId,omitempty"`
+	RunUrl     string          `json:"runUrl,omitempty"`
+	Created    string          `json:"created"`
+	Folders    []folderRequest `json:"folders"`
+}
+
+// requestMissingFolders fails before anything is uploaded when the plans
+// need folders that do not exist, uploading a request for them to the inbox
+// folder, so that an administrator creates them before the run is retried
+func requestMissingFolders(svc *drive.Service, planners []*planner, inbox string, dryRun bool) {
+	requests := folderRequests{
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		Workflow:   os.Getenv("GITHUB_WORKFLOW"),
+		RunId:      os.Getenv("GITHUB_RUN_ID"),
+		Created:    time.Now().UTC().Format(time.RFC3339),
+		Folders:    []folderRequest{},
+	}
+	if server := os.Getenv("GITHUB_SERVER_URL"); server != "" && requests.Repository != "" && requests.RunId != "" {
+		requests.RunUrl = fmt.Sprintf("%s/%s/actions/runs/%s", server, requests.Repository, requests.RunId)
+	}
+	for _, p := range planners {
+		for _, op := range p.plan.Operations {
+			if op.Action == actionCreateFolder {
+				requests.Folders = append(requests.Folders, folderRequest{
+					DestinationId: p.plan.FolderId,
+					Path:          op.Path,
+					Name:          op.Name,
+					ParentId:      p.folders[op.Folder],
+				})
+			}
+		}
+	}
+	if len(requests.Folders) == 0 {
+		return
+	}
+	var report strings.Builder
+	for _, f := range requests.Folders {
+		fmt.Fprintf(&report, "\n  %s in folder %s", f.Path, f.DestinationId)
+	}
+	if dryRun {
+		fmt.Printf("%d folder(s) would be requested from %s:%s\n", len(requests.Folders), inbox, report.String())
+		return
+	}
+
+	data, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding folder requests failed with error: %v", err))
+	}
+	tmp, err := os.CreateTemp(tempDir, "gdrive-upload-folder-requests-*.json")
+	if err != nil {
+		fatalf(fmt.Sprintf("writing folder requests failed with error: %v", err))
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	tmp.Close()
+	name := "folder-requests"
+	if requests.Repository != "" {
+		name += "-" + strings.ReplaceAll(requests.Repository, "/", "-")
+	}
+	if requests.RunId != "" {
+		name += "-" + requests.RunId
+	}
+	name += ".json"
+	f, err := uploadToDrive(svc, tmp.Name(), inbox, nil, name, "application/json", "", nil)
+	if err != nil {
+		fatalf(fmt.Sprintf("uploading folder requests to %s failed with error: %v", inbox, err))
+	}
+	fail(exitFailure, fmt.Sprintf("%d folder(s) do not exist and were requested in %s (%s), nothing was uploaded. retry once they are created:%s", len(requests.Folders), name, f.Id, report.String()))
+}
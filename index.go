@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	skipUnchangedInput = "skipUnchanged"
+	indexFileInput     = "indexFile"
+	defaultIndexFile   = ".gdrive-upload/index.json"
+)
+
+// indexEntry is what is known about a local file from previous runs: its
+// fingerprint, and where its content was last uploaded to
+type indexEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+	Sha256   string    `json:"sha256"`
+	Md5      string    `json:"md5"`
+	FolderId string    `json:"folderId,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	FileId   string    `json:"fileId,omitempty"`
+}
+
+// fileIndex is persisted between runs, by source path, so that unchanged
+// files are detected without hashing them or querying Google Drive
+type fileIndex struct {
+	Files map[string]indexEntry `json:"files"`
+}
+
+// index is nil unless skipUnchanged is enabled
+var index *fileIndex
+
+var indexFile string
+
+func loadIndex(path string) {
+	index = &fileIndex{Files: map[string]indexEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("No index found in %s, comparing files with Google Drive\n", path)
+		return
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading index from %s failed with error: %v", path, err))
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		githubactions.Warningf(fmt.Sprintf("parsing index from %s failed with error: %v, ignoring it", path, err))
+		index.Files = map[string]indexEntry{}
+	}
+	if index.Files == nil {
+		index.Files = map[string]indexEntry{}
+	}
+	fmt.Printf("Loaded index of %d file(s) from %s\n", len(index.Files), path)
+}
+
+// hashFile returns the fingerprint of a file, reusing the indexed one when
+// the size and modification time of the file did not change
+func (x *fileIndex) hashFile(path string) (fileHash, error) {
+	if x == nil {
+		return hashFile(path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileHash{}, err
+	}
+	e, ok := x.Files[path]
+	if ok && e.Size == fi.Size() && e.ModTime.Equal(fi.ModTime()) && e.Sha256 != "" && e.Md5 != "" {
+		return fileHash{Sha256: e.Sha256, Md5: e.Md5, Size: e.Size}, nil
+	}
+	h, err := hashFile(path)
+	if err != nil {
+		return h, err
+	}
+	if e.Sha256 != h.Sha256 {
+		// the content changed, so where it was uploaded to no longer applies
+		e = indexEntry{}
+	}
+	e.Size, e.ModTime, e.Sha256, e.Md5 = h.Size, fi.ModTime(), h.Sha256, h.Md5
+	x.Files[path] = e
+	return h, nil
+}
+
+// isUnchanged reports whether the same content was last uploaded to the
+// same target
+func (x *fileIndex) isUnchanged(op operation, folderId string) bool {
+	if x == nil || op.Md5 == "" {
+		return false
+	}
+	e, ok := x.Files[op.Source]
+	return ok && e.FileId != "" && e.FolderId == folderId && e.Target == op.Path && e.Md5 == op.Md5
+}
+
+func (x *fileIndex) record(op operation, folderId string, fileId string) {
+	if x == nil || op.Md5 == "" {
+		return
+	}
+	e := x.Files[op.Source]
+	e.Size, e.Sha256, e.Md5 = op.Size, op.Sha256, op.Md5
+	e.FolderId, e.Target, e.FileId = folderId, op.Path, fileId
+	x.Files[op.Source] = e
+}
+
+func writeIndex() {
+	if index == nil {
+		return
+	}
+	data, err := json.Marshal(index)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(indexFile), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(indexFile, data, 0644)
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing index to %s failed with error: %v", indexFile, err))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const inputsJsonInput = "inputsJson"
+
+// jsonValues holds the inputs given by inputsJson
+var jsonValues = map[string]string{}
+
+// knownInputs returns the names of all inputs
+func knownInputs() map[string]bool {
+	known := map[string]bool{}
+	for _, in := range cliInputs {
+		known[in.name] = true
+	}
+	return known
+}
+
+// loadInputsJson reads the inputs carried by the inputsJson document, used
+// for the inputs that are not set explicitly
+func loadInputsJson() {
+	doc := getInputUnenforced(inputsJsonInput)
+	if doc == "" {
+		return
+	}
+	values, problems := parseInputsJson([]byte(doc), "$")
+	if len(problems) > 0 {
+		invalidInput(fmt.Sprintf("input '%v' is invalid:\n  %s", inputsJsonInput, strings.Join(problems, "\n  ")))
+	}
+	jsonValues = values
+	fmt.Printf("Using %d input(s) from %v\n", len(values), inputsJsonInput)
+}
+
+// parseInputsJson parses a JSON object of inputs, returning their values and
+// the problems found, each prefixed with the path of the offending value
+// below root. Values are strings, numbers, booleans, arrays of strings for
+// inputs taking one value per line, or any JSON for inputs taking JSON.
+func parseInputsJson(data []byte, root string) (map[string]string, []string) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, []string{fmt.Sprintf("%s: %s", root, describeJsonError(data, err))}
+	}
+	values, problems := parseInputValues(raw, root)
+	return values, problems
+}
+
+// parseInputValues converts the JSON values of inputs to their string form
+func parseInputValues(raw map[string]json.RawMessage, root string) (map[string]string, []string) {
+	known := knownInputs()
+	values := map[string]string{}
+	var problems []string
+	var names []string
+	for k := range raw {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		path := root + "." + k
+		if k == inputsJsonInput || !known[k] {
+			msg := fmt.Sprintf("%s: unknown input", path)
+			if suggestion := closestInput(k, known); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+			}
+			problems = append(problems, msg)
+			continue
+		}
+		v, problem := inputValue(raw[k], path)
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+		values[k] = v
+	}
+	return values, problems
+}
+
+// jsonInputs are the inputs whose value is a JSON document
+var jsonInputs = map[string]bool{
+	permissionsPolicyInput: true,
+}
+
+func inputValue(raw json.RawMessage, path string) (string, string) {
+	name := path[strings.LastIndex(path, ".")+1:]
+	trimmed := bytes.TrimSpace(raw)
+	if jsonInputs[name] && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return string(trimmed), ""
+	}
+	var v interface{}
+	json.Unmarshal(raw, &v)
+	switch v := v.(type) {
+	case nil:
+		return "", ""
+	case string:
+		return strings.TrimSpace(v), ""
+	case bool, float64:
+		return string(trimmed), ""
+	case []interface{}:
+		lines := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Sprintf("%s[%d]: must be a string, got %s", path, i, jsonType(item))
+			}
+			lines[i] = s
+		}
+		return strings.Join(lines, "\n"), ""
+	}
+	return "", fmt.Sprintf("%s: must be a string, number, boolean or array of strings, got %s", path, jsonType(v))
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// describeJsonError adds the line and column of syntax errors
+func describeJsonError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var offset int64
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("must be an object, got %s", typeErr.Value)
+	default:
+		return err.Error()
+	}
+	// the offset is past the offending character
+	if offset > 0 {
+		offset--
+	}
+	line, col := 1, 1
+	for _, c := range data[:offset] {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Sprintf("%v at line %d, column %d", err, line, col)
+}
+
+// closestInput suggests the known input closest to a misspelled one
+func closestInput(name string, known map[string]bool) string {
+	best, bestDistance := "", 3
+	for k := range known {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(k)); d < bestDistance || d == bestDistance && best != "" && k < best {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInputsJson(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		want         map[string]string
+		wantProblems []string
+	}{
+		{
+			name: "strings are trimmed",
+			doc:  `{"name": " app.zip ", "folderId": "abc"}`,
+			want: map[string]string{"name": "app.zip", "folderId": "abc"},
+		},
+		{
+			name: "booleans and numbers",
+			doc:  `{"overwrite": true, "pipelineDepth": 2, "dryRun": false}`,
+			want: map[string]string{"overwrite": "true", "pipelineDepth": "2", "dryRun": "false"},
+		},
+		{
+			name: "arrays are one value per line",
+			doc:  `{"pathRewrite": ["^a/ => b/", "^c/ => d/"]}`,
+			want: map[string]string{"pathRewrite": "^a/ => b/\n^c/ => d/"},
+		},
+		{
+			name: "null is empty",
+			doc:  `{"description": null}`,
+			want: map[string]string{"description": ""},
+		},
+		{
+			name: "json inputs keep their document",
+			doc:  `{"permissionsPolicy": [{"type": "anyone", "role": "reader"}]}`,
+			want: map[string]string{"permissionsPolicy": `[{"type": "anyone", "role": "reader"}]`},
+		},
+		{
+			name:         "object value",
+			doc:          `{"name": {"a": 1}, "folderId": "abc"}`,
+			want:         map[string]string{"folderId": "abc"},
+			wantProblems: []string{"$.name: must be a string, number, boolean or array of strings, got object"},
+		},
+		{
+			name:         "array of non-strings",
+			doc:          `{"pathRewrite": ["^a/ => b/", 1]}`,
+			want:         map[string]string{},
+			wantProblems: []string{"$.pathRewrite[1]: must be a string, got number"},
+		},
+		{
+			name:         "unknown keys",
+			doc:          `{"nmae": "app.zip", "zzzzzzzzzz": 1}`,
+			want:         map[string]string{},
+			wantProblems: []string{"$.nmae: unknown input, did you mean 'name'?", "$.zzzzzzzzzz: unknown input"},
+		},
+		{
+			name:         "not an object",
+			doc:          `["name"]`,
+			wantProblems: []string{"$: must be an object, got array"},
+		},
+		{
+			name:         "syntax error",
+			doc:          "{\n  \"name\": \n}",
+			wantProblems: []string{"$: invalid character '}' looking for beginning of value at line 3, column 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := parseInputsJson([]byte(tt.doc), "$")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(problems, tt.wantProblems) {
+				t.Errorf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	logGroupsInput     = "logGroups"
+	logGroupsFile      = "file"
+	logGroupsDirectory = "directory"
+)
+
+// logGroup folds the log lines of each file, or of each directory, into a
+// collapsible group of the Actions log
+type logGroup struct {
+	mode    string
+	current string
+}
+
+var logGroups = &logGroup{}
+
+func parseLogGroups() {
+	mode := getInput(logGroupsInput)
+	if mode != "" && mode != logGroupsFile && mode != logGroupsDirectory {
+		invalidInput(fmt.Sprintf("invalid value '%v' for input '%v', must be %v or %v", mode, logGroupsInput, logGroupsFile, logGroupsDirectory))
+	}
+	logGroups.mode = mode
+}
+
+// enter starts the group of a file, given its path and the folder it belongs
+// to, unless the previous file already started it
+func (g *logGroup) enter(verb string, file string, folder string) {
+	key := file
+	if g.mode == logGroupsDirectory {
+		key = folder
+		if key == "" || key == "." {
+			key = "/"
+		}
+	}
+	if g.mode == "" || key == g.current {
+		return
+	}
+	g.end()
+	githubactions.Group(verb + " " + key)
+	g.current = key
+}
+
+// end ends the current group, if any
+func (g *logGroup) end() {
+	if g.current != "" {
+		githubactions.EndGroup()
+		g.current = ""
+	}
+}
+
+// sourceDir is the directory of a source file, which groups its logs in
+// directory mode
+func sourceDir(file string) string {
+	return path.Dir(normalizePath(file))
+}
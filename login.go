@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const loginTimeout = 5 * time.Minute
+
+// authorizedUser is the format of user credentials understood by Google
+// client libraries, accepted by the credentials input
+type authorizedUser struct {
+	Type         string `json:"type"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// login runs the OAuth loopback flow for a personal account and prints its
+// credentials, encoded in base64 for the credentials input, e.g.
+// `gdrive-upload login -clientSecretFile client_secret.json`
+func login(args []string) {
+	fs := flag.NewFlagSet("gdrive-upload login", flag.ExitOnError)
+	clientSecretFile := fs.String("clientSecretFile", "", "path of the JSON of an OAuth client of the desktop app type")
+	clientId := fs.String("clientId", "", "id of an OAuth client of the desktop app type, instead of clientSecretFile")
+	clientSecret := fs.String("clientSecret", "", "secret of the OAuth client given by clientId")
+	fs.Parse(args)
+	cliMode = true
+
+	var conf *oauth2.Config
+	if *clientSecretFile != "" {
+		data, err := os.ReadFile(*clientSecretFile)
+		if err != nil {
+			fatalf(fmt.Sprintf("reading %s failed with error: %v", *clientSecretFile, err))
+		}
+		conf, err = google.ConfigFromJSON(data, scope)
+		if err != nil {
+			invalidInput(fmt.Sprintf("parsing %s failed with error: %v", *clientSecretFile, err))
+		}
+	} else if *clientId != "" && *clientSecret != "" {
+		conf = &oauth2.Config{ClientID: *clientId, ClientSecret: *clientSecret, Endpoint: google.Endpoint, Scopes: []string{scope}}
+	} else {
+		fs.Usage()
+		invalidInput("either -clientSecretFile, or -clientId and -clientSecret are required")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fatalf(fmt.Sprintf("listening for the redirect failed with error: %v", err))
+	}
+	conf.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+	state, err := randomString()
+	if err != nil {
+		fatalf(fmt.Sprintf("generating the state failed with error: %v", err))
+	}
+	verifier, err := randomString()
+	if err != nil {
+		fatalf(fmt.Sprintf("generating the code verifier failed with error: %v", err))
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL := conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce,
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+
+	codes := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		}
+		if e := r.URL.Query().Get("error"); e != "" {
+			fmt.Fprintf(w, "Authorization failed: %s. You can close this window.", e)
+			codes <- ""
+			return
+		}
+		fmt.Fprint(w, "Authorized. You can close this window and go back to the terminal.")
+		codes <- r.URL.Query().Get("code")
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Fprintf(os.Stderr, "Open this URL in a browser on this machine and authorize the access to Google Drive:\n\n%s\n\n", authURL)
+	var code string
+	select {
+	case code = <-codes:
+	case <-time.After(loginTimeout):
+		fail(exitAuth, fmt.Sprintf("no authorization received within %v", loginTimeout))
+	}
+	if code == "" {
+		fail(exitAuth, "the access to Google Drive was not authorized")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	token, err := conf.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		fail(exitAuth, fmt.Sprintf("exchanging the authorization code failed with error: %v", err))
+	}
+	if token.RefreshToken == "" {
+		fail(exitAuth, "Google returned no refresh token")
+	}
+	data, _ := json.Marshal(authorizedUser{Type: "authorized_user", ClientId: conf.ClientID, ClientSecret: conf.ClientSecret, RefreshToken: token.RefreshToken})
+	fmt.Fprintln(os.Stderr, "Credentials for the credentials input, keep them secret:")
+	fmt.Println(base64.StdEncoding.EncodeToString(data))
+}
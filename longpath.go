@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	longPathsInput    = "longPaths"
+	longPathsFail     = "fail"
+	longPathsTruncate = "truncate"
+
+	// maxFolderDepth is how many levels of folders Drive allows below the
+	// destination
+	maxFolderDepth = 100
+	// maxNameBytes is the longest name kept, the limit of most filesystems,
+	// so that the files can still be synced to a computer
+	maxNameBytes = 255
+)
+
+// parseLongPaths returns how paths exceeding the limits are handled
+func parseLongPaths() string {
+	mode := getInput(longPathsInput)
+	switch mode {
+	case "":
+		return longPathsFail
+	case longPathsFail, longPathsTruncate:
+		return mode
+	}
+	invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", longPathsInput, longPathsFail, longPathsTruncate, mode))
+	return ""
+}
+
+// fitPath checks the folders and name a file is uploaded to against the
+// limits of Drive, failing or shortening them depending on mode. Folders
+// deeper than the limit are merged into the last allowed one, and long names
+// are truncated with a hash suffix keeping them unique.
+func fitPath(mode string, file string, dirs []string, name string) ([]string, string) {
+	if len(dirs) > maxFolderDepth {
+		if mode == longPathsFail {
+			fail(exitFailure, fmt.Sprintf("%s would be uploaded %d folders deep, Drive allows at most %d. shorten the path or set '%v' to %v", file, len(dirs), maxFolderDepth, longPathsInput, longPathsTruncate))
+		}
+		merged := strings.Join(dirs[maxFolderDepth-1:], "-")
+		dirs = append(append([]string{}, dirs[:maxFolderDepth-1]...), merged)
+		fmt.Printf("Merging the folders of %s deeper than %d levels into %s\n", file, maxFolderDepth, merged)
+	}
+	fitted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		fitted[i] = fitName(mode, file, dir)
+	}
+	return fitted, fitName(mode, file, name)
+}
+
+func fitName(mode string, file string, name string) string {
+	if len(name) <= maxNameBytes {
+		return name
+	}
+	if mode == longPathsFail {
+		fail(exitFailure, fmt.Sprintf("the name %s... of %s is %d bytes long, more than %d. shorten it or set '%v' to %v", name[:32], file, len(name), maxNameBytes, longPathsInput, longPathsTruncate))
+	}
+	sum := sha256.Sum256([]byte(name))
+	ext := path.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	suffix := "~" + hex.EncodeToString(sum[:4]) + ext
+	prefix := name[:maxNameBytes-len(suffix)]
+	// do not cut a character in half
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	short := prefix + suffix
+	fmt.Printf("Truncating the name %s to %s\n", name, short)
+	return short
+}
@@ -11,14 +11,21 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
 )
@@ -30,87 +37,415 @@ const (
 	folderIdInput            = "folderId"
 	credentialsInput         = "credentials"
 	overwrite                = "false"
+	overwriteInput           = "overwrite"
 	mimeTypeInput            = "mimeType"
 	useCompleteSourceName    = "useCompleteSourceFilenameAsName"
 	mirrorDirectoryStructure = "mirrorDirectoryStructure"
 	namePrefixInput          = "namePrefix"
+	appendSourceExtInput     = "appendSourceExtension"
+	folderCreateBackoffInput = "folderCreateBackoff"
+	folderPropertyInput      = "folderProperty"
 )
 
-func uploadToDrive(svc *drive.Service, filename string, folderId string, driveFile *drive.File, name string, mimeType string) {
-	fi, err := os.Lstat(filename)
-	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("lstat of file with filename: %v failed with error: %v", filename, err))
-	}
-	if fi.IsDir() {
-		fmt.Printf("%s is a directory. skipping upload.", filename)
-		return
-	}
-	file, err := os.Open(filename)
-	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("opening file with filename: %v failed with error: %v", filename, err))
+// folderCreateBackoff is the maximum random delay before creating a folder
+var folderCreateBackoff time.Duration
+
+func uploadToDrive(svc *drive.Service, filename string, folderId string, driveFile *drive.File, name string, mimeType string, description string, appProperties map[string]string) (*drive.File, error) {
+	return uploadToDriveContext(context.Background(), svc, filename, folderId, driveFile, name, mimeType, description, appProperties, "", "")
+}
+
+// uploadToDriveContext uploads a file, aborting the upload once ctx is done.
+// createdTime is only set on new files, as Drive does not allow changing it.
+func uploadToDriveContext(ctx context.Context, svc *drive.Service, filename string, folderId string, driveFile *drive.File, name string, mimeType string, description string, appProperties map[string]string, createdTime string, modifiedTime string) (*drive.File, error) {
+	var file io.Reader
+	var size int64
+	if filename == stdinFilename {
+		if execCommand != "" {
+			fmt.Println("Streaming upload from the output of the command")
+		} else {
+			fmt.Println("Streaming upload from stdin")
+		}
+		file = stdinReader
+	} else {
+		fi, err := os.Lstat(filename)
+		if err != nil {
+			return nil, fmt.Errorf("lstat of file with filename: %v failed with error: %v", filename, err)
+		}
+		if fi.IsDir() {
+			fmt.Printf("%s is a directory. skipping upload.", filename)
+			return nil, nil
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("opening file with filename: %v failed with error: %v", filename, err)
+		}
+		defer f.Close()
+		file = f
+		size = fi.Size()
 	}
+	media := mediaOptions(size, filename != stdinFilename)
+
+	var uploaded *drive.File
+	var err error
 
 	if driveFile != nil {
 		f := &drive.File{
-			Name:     name,
-			MimeType: mimeType,
+			Name:          name,
+			MimeType:      mimeType,
+			Description:   description,
+			AppProperties: appProperties,
+			ModifiedTime:  modifiedTime,
 		}
-		_, err = svc.Files.Update(driveFile.Id, f).AddParents(folderId).Media(file).SupportsAllDrives(true).Do()
+		uploaded, err = svc.Files.Update(driveFile.Id, f).AddParents(folderId).Media(file, media).Fields("id,name,size").SupportsAllDrives(allDrives).Context(ctx).Do()
 	} else {
 		f := &drive.File{
-			Name:     name,
-			MimeType: mimeType,
-			Parents:  []string{folderId},
+			Name:          name,
+			MimeType:      mimeType,
+			Description:   description,
+			AppProperties: appProperties,
+			Parents:       []string{folderId},
+			CreatedTime:   createdTime,
+			ModifiedTime:  modifiedTime,
 		}
-		_, err = svc.Files.Create(f).Media(file).SupportsAllDrives(true).Do()
+		uploaded, err = svc.Files.Create(f).Media(file, media).Fields("id,name,size").SupportsAllDrives(allDrives).Context(ctx).Do()
 	}
 
 	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("creating/updating file failed with error: %v", err))
-	} else {
-		githubactions.Debugf("Uploaded/Updated file.")
+		return nil, fmt.Errorf("creating/updating file failed with error: %w", err)
 	}
+	githubactions.Debugf("Uploaded/Updated file.")
+	return uploaded, nil
 }
 
 func main() {
 
+	// mint the credentials of a personal account
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		login(os.Args[2:])
+		return
+	}
+
+	// check the config without contacting Google Drive
+	if len(os.Args) > 1 && os.Args[1] == actionValidate {
+		validateCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		schemaCommand()
+		return
+	}
+
+	// run in CLI mode when the binary is invoked with arguments
+	if len(os.Args) > 1 {
+		cliMode = true
+		parseCLI(os.Args[1:])
+	}
+	parseOnError()
+	switch action := getInputUnenforced(actionInput); action {
+	case "", actionUpload:
+	case actionValidate:
+		validateInputs()
+		return
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", actionInput, actionUpload, actionValidate, action))
+	}
+
+	// load inputs from inputsJson and defaults from the selected profile
+	loadInputsJson()
+	loadProfile()
+	configureTLS()
+	parseSharedDriveSupport()
+
+	// let the owners of the destination folder control the inputs, unless
+	// applying a plan already made with them
+	if getInput(applyPlanInput) == "" {
+		loadUploadPolicy()
+	}
+	// the profile or inputsJson may set onError too
+	parseOnError()
+
+	// export upload telemetry if an OTLP endpoint is configured
+	initTelemetry()
+	metricsFile = getInput(metricsFileInput)
+	parsePacingInputs()
+	parseLogGroups()
+	parseTempDir()
+	metadataSidecars = getBoolInput(metadataSidecarsInput)
+	loadRegistry()
+	parseBatchSize()
+	parseFileTimeout()
+	parseUploadTiers()
+	parseProvenance()
+	loadFileDates()
+	parseOnMissing()
+	parseNamePattern()
+
+	// get the maximum random delay before creating folders
+	if backoff := getInput(folderCreateBackoffInput); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil {
+			invalidInput(fmt.Sprintf("invalid duration for input '%v': %v", folderCreateBackoffInput, err))
+		}
+		folderCreateBackoff = d
+		rand.Seed(time.Now().UnixNano())
+	}
+
+	// get the maximum time to wait for uploaded files to be listed
+	if wait := getInput(waitForVisibilityInput); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			invalidInput(fmt.Sprintf("invalid duration for input '%v': %v", waitForVisibilityInput, err))
+		}
+		visibilityTimeout = d
+	}
+
+	// get the maximum time to wait for the previews of uploaded files
+	if wait := getInput(waitForPreviewsInput); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			invalidInput(fmt.Sprintf("invalid duration for input '%v': %v", waitForPreviewsInput, err))
+		}
+		previewsTimeout = d
+	}
+
+	// resume an interrupted session, or apply a previously generated plan,
+	// instead of planning the upload
+	resumed := openQueue()
+	if applyPlanFile := getInput(applyPlanInput); applyPlanFile != "" || resumed != nil {
+		pl, verify := resumed, false
+		if pl == nil {
+			pl, verify = loadPlan(applyPlanFile), true
+		}
+		svc := newDriveService()
+		if alreadyCompleted(svc, pl.FolderId) {
+			finishRun()
+			return
+		}
+		writeStatus(svc, pl, statusInProgress)
+		applyPlan(svc, pl, verify)
+		waitForVisibility(svc)
+		waitForPreviews(svc)
+		computeRootDigest()
+		uploadManifest(svc, pl)
+		updateChangelog(svc, pl)
+		updateChecksums(svc, pl)
+		pinUploads(svc, pl)
+		writeStatus(svc, pl, finalStatus())
+		recordCompleted(svc, pl.FolderId)
+		enforcePermissions(svc, pl)
+		usageReport(svc, pl.FolderId)
+		writeTreeSnapshot(svc, pl.FolderId)
+		finishRun()
+		return
+	}
+
+	// report the ownership of the destination subtree instead of uploading
+	if getBoolInput(ownershipReportInput) {
+		svc := newDriveService()
+		ownershipReport(svc, destinationFolderId(svc))
+		return
+	}
+
+	// maintain a shared drive instead of uploading
+	if maintenance := getInput(sharedDriveMaintenanceInput); maintenance != "" {
+		driveId := checkSharedDriveMaintenance(maintenance)
+		svc := newDriveService()
+		switch maintenance {
+		case maintenanceMembers:
+			sharedDriveMembers(svc, driveId)
+		case maintenancePendingDeletion:
+			reportPendingDeletion(svc, driveId)
+		case maintenanceMove:
+			moveSharedDriveContent(svc, driveId, destinationFolderId(svc), getBoolInput(dryRunInput))
+		}
+		finishRun()
+		return
+	}
+
+	// copy a folder of Google Drive server-side instead of uploading
+	if sourceId := getInput(copyFromFolderIdInput); sourceId != "" {
+		svc := newDriveService()
+		backupFolder(svc, sourceId, destinationFolderId(svc))
+		finishRun()
+		return
+	}
+
+	// download the files of a manifest instead of uploading
+	if source := getInput(restoreManifestInput); source != "" {
+		svc := newDriveService()
+		dir := getInput(restoreDirInput)
+		if dir == "" {
+			dir = "."
+		}
+		restoreFiles(svc, readRestoreManifest(svc, source), dir)
+		finishRun()
+		return
+	}
+
+	// delete the files uploaded by a workflow or for a branch instead of
+	// uploading
+	if getInput(cleanupInput) != "" {
+		svc := newDriveService()
+		cleanupByProvenance(svc, destinationFolderId(svc), parseCleanupFilters(getInput(cleanupInput)), getBoolInput(dryRunInput))
+		finishRun()
+		return
+	}
+
+	// delete the files older than the retention instead of uploading
+	if getInput(retainForInput) != "" {
+		retainFor, labels := parseRetention()
+		svc := newDriveService()
+		pruneByRetention(svc, destinationFolderId(svc), retainFor, labels, getBoolInput(dryRunInput))
+		finishRun()
+		return
+	}
+
+	// remove the preview of a pull request once it is closed
+	if closedPreview() {
+		svc := newDriveService()
+		removePreview(svc, destinationFolderId(svc))
+		finishRun()
+		return
+	}
+
+	// deploy an Apps Script project instead of uploading files
+	if source := getInput(appsScriptInput); source != "" {
+		pushAppsScript(newDriveService(), source)
+		finishRun()
+		return
+	}
+
+	// only take a snapshot of the destination tree when there is nothing to
+	// upload
+	if getInput(filenameInput) == "" && getInput(treeSnapshotInput) != "" {
+		svc := newDriveService()
+		writeTreeSnapshot(svc, destinationFolderId(svc))
+		return
+	}
+
 	// get filename argument from action input
-	filename := githubactions.GetInput(filenameInput)
+	filename := getInput(filenameInput)
 	if filename == "" {
 		missingInput(filenameInput)
 	}
-	files, err := filepath.Glob(filename)
-	fmt.Printf("Files: %v\n", files)
-	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("Invalid filename pattern: %v", err))
+	var files []string
+	var err error
+	if filename == stdinFilename {
+		files = []string{stdinFilename}
+	} else {
+		files, err = filepath.Glob(filename)
+		fmt.Printf("Files: %v\n", files)
+		if err != nil {
+			invalidInput(fmt.Sprintf("Invalid filename pattern: %v", err))
+		}
+		if len(files) == 0 {
+			fail(exitNoMatch, fmt.Sprintf("No file found! pattern: %s", filename))
+		}
+	}
+
+	// more than one matched file always uses the source filenames, even when
+	// only some of them are uploaded, unless they are named after the capture
+	// groups of namePattern
+	useSourceFilename := len(files) > 1 && namePattern == nil
+
+	// skip files still being written by a previous step
+	if window := getInput(stabilityWindowInput); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			invalidInput(fmt.Sprintf("invalid duration for input '%v': %v", stabilityWindowInput, err))
+		}
+		files = stableFiles(files, d)
+		if len(files) == 0 {
+			fail(exitNoMatch, fmt.Sprintf("all files matching %s are still being written", filename))
+		}
+	}
+	if getBoolInput(respectGitignoreInput) {
+		files = filterGitignored(files)
+		if len(files) == 0 {
+			fail(exitNoMatch, fmt.Sprintf("all files matching %s are ignored by .gitignore", filename))
+		}
 	}
+	files = excludeByMimeType(files)
 	if len(files) == 0 {
-		githubactions.Fatalf(fmt.Sprintf("No file found! pattern: %s", filename))
+		fail(exitNoMatch, fmt.Sprintf("all files matching %s are excluded by their MIME type", filename))
+	}
+	files = limitTotalSize(files)
+	if len(files) == 0 {
+		fail(exitNoMatch, fmt.Sprintf("no file matching %s fits in %v", filename, maxTotalSizeInput))
+	}
+	files = scanFiles(files)
+	if len(files) == 0 {
+		fail(exitNoMatch, fmt.Sprintf("all files matching %s are infected", filename))
+	}
+
+	// only upload the files that failed in the previous attempt of the run
+	failuresFile = getInput(failuresFileInput)
+	if getBoolInput(retryFailedOnlyInput) {
+		if failuresFile == "" {
+			failuresFile = defaultFailuresFile
+			resolvedInputs[failuresFileInput] = failuresFile
+		}
+		files = filterFailedOnly(files)
+		if len(files) == 0 {
+			fmt.Println("No failed files to retry")
+			writeFailuresFile()
+			outputFailures()
+			return
+		}
+	}
+
+	// skip files already uploaded by a previous attempt of the run
+	if getBoolInput(skipUploadedInput) {
+		stateFile = getInput(stateFileInput)
+		if stateFile == "" {
+			stateFile = defaultStateFile
+			resolvedInputs[stateFileInput] = stateFile
+		}
+		loadUploadState(stateFile)
+	}
+
+	// skip files unchanged since they were last uploaded
+	if getBoolInput(skipUnchangedInput) {
+		indexFile = getInput(indexFileInput)
+		if indexFile == "" {
+			indexFile = defaultIndexFile
+			resolvedInputs[indexFileInput] = indexFile
+		}
+		loadIndex(indexFile)
 	}
 
 	// get overwrite flag
 	var overwriteFlag bool
-	overwrite := githubactions.GetInput("overwrite")
+	overwrite := getInput(overwriteInput)
 	if overwrite == "" {
 		githubactions.Warningf("Overwrite is disabled.")
 		overwriteFlag = false
 	} else {
 		overwriteFlag, _ = strconv.ParseBool(overwrite)
 	}
+	overwriteCheck = getInput(overwriteCheckInput)
+	if overwriteCheck != "" && overwriteCheck != overwriteCheckVersion && overwriteCheck != overwriteCheckContent {
+		invalidInput(fmt.Sprintf("invalid value '%v' for input '%v', must be %v or %v", overwriteCheck, overwriteCheckInput, overwriteCheckVersion, overwriteCheckContent))
+	}
+	parseConflictExpression()
 	// get name argument from action input
-	name := githubactions.GetInput(nameInput)
+	name := getInput(nameInput)
+	if filename == stdinFilename && name == "" {
+		invalidInput(fmt.Sprintf("input '%v' is required when uploading from stdin", nameInput))
+	}
 
-	// get folderId argument from action input
-	folderId := githubactions.GetInput(folderIdInput)
-	if folderId == "" {
+	// get folderId argument from action input, or the marker identifying the folder
+	folderId := getInput(folderIdInput)
+	folderProperty := getInput(folderPropertyInput)
+	if folderId == "" && folderProperty == "" {
 		missingInput(folderIdInput)
 	}
 
 	// get file mimeType argument from action input
-	mimeType := githubactions.GetInput(mimeTypeInput)
+	mimeType := getInput(mimeTypeInput)
 
 	var useCompleteSourceFilenameAsNameFlag bool
-	useCompleteSourceFilenameAsName := githubactions.GetInput(useCompleteSourceName)
+	useCompleteSourceFilenameAsName := getInput(useCompleteSourceName)
 	if useCompleteSourceFilenameAsName == "" {
 		fmt.Println("useCompleteSourceFilenameAsName is disabled.")
 		useCompleteSourceFilenameAsNameFlag = false
@@ -119,155 +454,522 @@ func main() {
 	}
 
 	var mirrorDirectoryStructureFlag bool
-	mirrorDirectoryStructure := githubactions.GetInput(mirrorDirectoryStructure)
+	mirrorDirectoryStructure := getInput(mirrorDirectoryStructure)
 	if mirrorDirectoryStructure == "" {
 		fmt.Println("mirrorDirectoryStructure is disabled.")
 		mirrorDirectoryStructureFlag = false
 	} else {
 		mirrorDirectoryStructureFlag, _ = strconv.ParseBool(mirrorDirectoryStructure)
 	}
+	// get the description of the uploaded files
+	description := getInput(descriptionInput)
+	// the commit message is appended as is, never rendered as a template
+	var commitDescription string
+	if getBoolInput(descriptionFromCommitInput) {
+		commitDescription = commitMessage()
+		if commitDescription == "" {
+			githubactions.Warningf("could not find the message of the triggering commit, uploading without description")
+		}
+	}
+
+	// group files by their top-level directory when not mirroring
+	groupByTopDirFlag := getBoolInput(groupByTopDirInput)
+	if groupByTopDirFlag && mirrorDirectoryStructureFlag {
+		invalidInput(fmt.Sprintf("inputs '%v' and '%v' cannot be used together", groupByTopDirInput, "mirrorDirectoryStructure"))
+	}
+
+	// get the rules rewriting mirrored paths
+	rewriteRules := parseRewriteRules(getInput(pathRewriteInput))
+
+	// carry the extension of the source file over to names without one
+	appendSourceExtFlag := getBoolInput(appendSourceExtInput)
+
 	// get filename prefix
-	filenamePrefix := githubactions.GetInput(namePrefixInput)
+	filenamePrefix := getInput(namePrefixInput)
 
-	// get base64 encoded credentials argument from action input
-	credentials := githubactions.GetInput(credentialsInput)
-	if credentials == "" {
-		missingInput(credentialsInput)
+	// get the layout of the uploaded files and the name of the manifest
+	layout := getInput(layoutInput)
+	if layout != "" && layout != "flat" && layout != layoutCas {
+		invalidInput(fmt.Sprintf("invalid value '%v' for input '%v', must be flat or %v", layout, layoutInput, layoutCas))
+	}
+	manifestName := getInput(manifestNameInput)
+	if layout == layoutCas && manifestName == "" {
+		manifestName = defaultCasManifestName
 	}
-	// add base64 encoded credentials argument to mask
-	githubactions.AddMask(credentials)
 
-	// decode credentials to []byte
-	decodedCredentials, err := base64.StdEncoding.DecodeString(credentials)
-	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("base64 decoding of 'credentials' failed with error: %v", err))
+	// get how symlinks are uploaded, following them unless disabled
+	followSymlinksFlag := getInput(followSymlinksInput) == "" || getBoolInput(followSymlinksInput)
+	symlinksAsShortcutsFlag := getBoolInput(symlinksAsShortcutsInput)
+	var symlinks map[string]string
+	if !followSymlinksFlag {
+		symlinks = symlinkTargets(files)
 	}
 
-	creds := strings.TrimSuffix(string(decodedCredentials), "\n")
+	// get how unchanged and append-only files are detected
+	parseTransferInputs()
 
-	// add decoded credentials argument to mask
-	githubactions.AddMask(creds)
+	// record the permissions and owner of the uploaded files
+	recordPermissions = getBoolInput(recordPermissionsInput)
+	recordOwnership = getBoolInput(recordOwnershipInput)
 
-	// fetching a JWT config with credentials and the right scope
-	conf, err := google.JWTConfigFromJSON([]byte(creds), scope)
-	if err != nil {
-		githubactions.Fatalf(fmt.Sprintf("fetching JWT credentials failed with error: %v", err))
+	// get dry run flag and the file the plan is written to
+	dryRunFlag := getBoolInput(dryRunInput)
+	planFile := getInput(planFileInput)
+
+	// get the transforms applied to copies of the files before uploading
+	transforms := append(parseTransforms(getInput(transformInput)), gzipRules(getInput(gzipPatternsInput))...)
+	if len(transforms) > 0 && planFile != "" {
+		invalidInput(fmt.Sprintf("inputs '%v' and '%v' cannot be used with '%v', transformed files only exist during the run", transformInput, gzipPatternsInput, planFileInput))
 	}
+	checkScratchSpace(files, transforms)
+	defer removeTransformed()
 
-	// instantiating a new drive service
-	ctx := context.Background()
-	svc, err := drive.New(conf.Client(ctx))
-	if err != nil {
-		log.Println(err)
+	svc := newDriveService()
+
+	if folderId == "" {
+		folderId = findFolderByProperty(svc, folderProperty)
+	}
+	// scaffold the destination from a template folder before uploading
+	if templateId := getInput(templateFolderIdInput); templateId != "" {
+		folderId = instantiateTemplate(svc, templateId, folderId, dryRunFlag)
+	}
+	// upload the preview of a pull request into a folder of its own
+	previewMode := getBoolInput(previewModeInput)
+	if previewMode {
+		folderId = previewFolder(svc, folderId, dryRunFlag)
+	}
+
+	// fail fast if the paths the run relies on do not exist
+	requireRemotePaths(svc, folderId)
+	if folderId != "" && alreadyCompleted(svc, folderId) {
+		finishRun()
+		return
 	}
 
-	useSourceFilename := len(files) > 1
+	// list the destination once instead of looking up each file, unless a
+	// dry run plans against a folder it did not create
+	if getBoolInput(warmStartInput) && folderId != "" {
+		loadRemoteTree(svc, folderId)
+	}
 
-	// Save the folderId because it might get overwritten by createDriveDirectory
-	originalFolderId := folderId
+	pl := newPlanner(svc, folderId)
+	if checksumsName := getInput(checksumsNameInput); checksumsName != "" {
+		pl.plan.Checksums = checksumsName
+		pl.checksums = loadChecksums(svc, folderId, checksumsName)
+	}
+	// the same files are planned for the primary destination and each replica
+	planners := []*planner{pl}
+	replicaCopy := getBoolInput(replicaCopyInput)
+	for _, replicaId := range parseReplicas() {
+		if filename == stdinFilename && !replicaCopy {
+			invalidInput(fmt.Sprintf("input '%v' is required to replicate uploads from stdin", replicaCopyInput))
+		}
+		r := newReplicaPlanner(svc, replicaId, replicaCopy)
+		pl.plan.Replicas = append(pl.plan.Replicas, r.plan)
+		planners = append(planners, r)
+	}
+	routes := parseRoutes(getInput(routeByExtensionInput))
+	if len(routes) > 0 && (len(planners) > 1 || layout == layoutCas) {
+		invalidInput(fmt.Sprintf("input '%v' cannot be combined with '%v' or layout '%v'", routeByExtensionInput, replicasInput, layoutCas))
+	}
+	longPaths := parseLongPaths()
+	// transform and hash the next files while the current ones are planned
+	hashing := state != nil || index != nil || compareContent || pl.checksums != nil || metadataSidecars || conflictTemplate != nil || layout == layoutCas
+	startPipeline(files, transforms, hashing)
+	// shortcuts are planned once the files they point to are
+	type pendingShortcut struct {
+		file, target, name string
+		dirs               []string
+	}
+	var shortcuts []pendingShortcut
 	for _, file := range files {
-		folderId = originalFolderId
+		if skipMissing(operation{Source: file}) {
+			pipeline.release(file)
+			continue
+		}
 		var targetName string
+		var directoryStructure []string
+		logGroups.enter("Planning", file, sourceDir(file))
 		fmt.Printf("Processing file %s\n", file)
-		if mirrorDirectoryStructureFlag {
-			directoryStructure := strings.Split(filepath.Dir(file), string(os.PathSeparator))
+		sourcePath := normalizePath(file)
+		if mirrorDirectoryStructureFlag && file != stdinFilename {
+			sourcePath = rewritePath(rewriteRules, sourcePath)
+			if dir := path.Dir(sourcePath); dir != "." {
+				directoryStructure = strings.Split(dir, "/")
+			}
 			fmt.Printf("Mirroring directory structure: %v\n", directoryStructure)
-			for _, dir := range directoryStructure {
-				folderId, err = createDriveDirectory(svc, folderId, dir)
+		} else if groupByTopDirFlag && file != stdinFilename {
+			if dir := topDir(filename, sourcePath); dir != "" {
+				directoryStructure = []string{dir}
+				fmt.Printf("Grouping into folder %s\n", dir)
 			}
 		}
-		if useCompleteSourceFilenameAsNameFlag {
+		if file == stdinFilename {
+			targetName = expandName(nameInput, name, file)
+		} else if useCompleteSourceFilenameAsNameFlag {
 			targetName = file
-		} else if useSourceFilename || name == "" {
-			targetName = filepath.Base(file)
+		} else if useSourceFilename || name == "" || namePattern != nil && matchGroups(file) == nil {
+			targetName = path.Base(sourcePath)
 		} else {
-			targetName = name
+			targetName = expandName(nameInput, name, file)
+			// keep the type association of renamed uploads
+			if appendSourceExtFlag && path.Ext(targetName) == "" {
+				targetName += path.Ext(sourcePath)
+			}
 		}
 		if targetName == "" {
-			githubactions.Fatalf("Could not discover target file name")
+			fatalf("Could not discover target file name")
 		} else if filenamePrefix != "" {
-			targetName = filenamePrefix + targetName
+			targetName = expandName(namePrefixInput, filenamePrefix, file) + targetName
+		}
+		if file != stdinFilename {
+			targetName += pipeline.transform(transforms, file)
+		}
+		// names are uploaded and matched in NFC, whatever the form of local names
+		targetName = nfc(targetName)
+		for i, dir := range directoryStructure {
+			directoryStructure[i] = nfc(dir)
+		}
+		directoryStructure, targetName = fitPath(longPaths, file, directoryStructure, targetName)
+		dirs := directoryStructure
+		if folder := routeFolder(routes, file); folder != "" {
+			fmt.Printf("Routing %s to folder %s\n", file, folder)
+			dirs = append([]string{pl.route(folder)}, directoryStructure...)
+		}
+		if target, ok := symlinks[file]; ok {
+			if symlinksAsShortcutsFlag && target != "" {
+				shortcuts = append(shortcuts, pendingShortcut{file: file, target: target, name: targetName, dirs: dirs})
+			} else {
+				fmt.Printf("%s is a symlink, skipping\n", file)
+			}
+			continue
+		}
+		fileDescription := expandName(descriptionInput, description, file)
+		if commitDescription != "" {
+			if fileDescription != "" {
+				fileDescription += "\n\n"
+			}
+			fileDescription = truncate(fileDescription+commitDescription, maxDescriptionLength)
+		}
+		for _, p := range planners {
+			if layout == layoutCas {
+				p.addContentAddressed(file, remotePath(strings.Join(directoryStructure, "/"), targetName), mimeType, fileDescription)
+			} else {
+				p.addFile(file, dirs, targetName, mimeType, fileDescription, overwriteFlag)
+			}
+		}
+	}
+	logGroups.end()
+	for _, s := range shortcuts {
+		for _, p := range planners {
+			p.addShortcut(s.file, s.target, s.dirs, s.name)
 		}
-		uploadFile(svc, file, folderId, targetName, mimeType, overwriteFlag)
 	}
+	if manifestName != "" {
+		pl.plan.Manifest = expandName(manifestNameInput, manifestName, "")
+		resolvedInputs[manifestNameInput] = pl.plan.Manifest
+		fmt.Printf("Manifest %s will be uploaded after applying the plan\n", pl.plan.Manifest)
+	}
+	if changelog := getInput(changelogInput); changelog != "" {
+		pl.plan.Changelog = expandName(changelogInput, changelog, "")
+		resolvedInputs[changelogInput] = pl.plan.Changelog
+		fmt.Printf("Changelog %s will be updated after applying the plan\n", pl.plan.Changelog)
+	}
+
+	printPlan(pl.plan)
+	if inbox := getInput(folderRequestsInboxInput); inbox != "" {
+		requestMissingFolders(svc, planners, inbox, dryRunFlag)
+	}
+	if dryRunFlag {
+		fmt.Println("Dry run enabled. Nothing will be changed in Google Drive.")
+		outputPlan(pl.plan, planFile)
+		outputConfig()
+		return
+	}
+	writeStatus(svc, pl.plan, statusInProgress)
+	applyPlan(svc, pl.plan, false)
+	waitForVisibility(svc)
+	waitForPreviews(svc)
+	computeRootDigest()
+	uploadManifest(svc, pl.plan)
+	updateChangelog(svc, pl.plan)
+	updateChecksums(svc, pl.plan)
+	pinUploads(svc, pl.plan)
+	writeStatus(svc, pl.plan, finalStatus())
+	recordCompleted(svc, pl.plan.FolderId)
+	enforcePermissions(svc, pl.plan)
+	usageReport(svc, pl.plan.FolderId)
+	writeTreeSnapshot(svc, pl.plan.FolderId)
+	if previewMode {
+		commentPreviewLink(pl.plan.FolderId)
+	}
+	finishRun()
 }
 
-func createDriveDirectory(svc *drive.Service, folderId string, name string) (string, error) {
+// finishRun exports the statistics and failures of the run, and fails the
+// step if any file failed to upload
+func finishRun() {
+	removeTransformed()
+	otel.export()
+	writeMetricsFile(metricsFile)
+	writeFailuresFile()
+	writeUploadState()
+	journal.finish()
+	writeIndex()
+	writeRegistry()
+	stats.report()
+	apiPacer.report()
+	outputConfig()
+	outputFiles()
+	outputFailures()
+	outputSlowFiles()
+	outputMissingFiles()
+	if len(failedFiles) > 0 {
+		code := exitPartial
+		if quotaExceeded {
+			code = exitQuota
+		}
+		fail(code, fmt.Sprintf("%d file(s) failed to upload", len(failedFiles)))
+	}
+}
+
+// driveService is the service of the run, created once
+var driveService *drive.Service
+
+// driveClient is the authorized client of driveService, for the requests the
+// Drive client does not support
+var driveClient *http.Client
+
+func newDriveService() *drive.Service {
+	if driveService != nil {
+		return driveService
+	}
+	// get base64 encoded credentials argument from action input
+	credentials := getInput(credentialsInput)
+	if credentials == "" {
+		missingInput(credentialsInput)
+	}
+	// add base64 encoded credentials argument to mask
+	githubactions.AddMask(credentials)
+
+	// decode credentials to []byte
+	decodedCredentials, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		fail(exitAuth, fmt.Sprintf("base64 decoding of 'credentials' failed with error: %v", err))
+	}
+
+	creds := strings.TrimSuffix(string(decodedCredentials), "\n")
+
+	// add decoded credentials argument to mask
+	githubactions.AddMask(creds)
+
+	// instantiating a new drive service, with the credentials of a personal
+	// account minted by the login subcommand or of a service account
+	ctx := context.Background()
+	var source oauth2.TokenSource
+	var user authorizedUser
+	if json.Unmarshal([]byte(creds), &user) == nil && user.Type == "authorized_user" {
+		c, err := google.CredentialsFromJSON(ctx, []byte(creds), scope)
+		if err != nil {
+			fail(exitAuth, fmt.Sprintf("parsing user credentials failed with error: %v", err))
+		}
+		source = c.TokenSource
+	} else {
+		// fetching a JWT config with credentials and the right scope
+		conf, err := google.JWTConfigFromJSON([]byte(creds), scope)
+		if err != nil {
+			fail(exitAuth, fmt.Sprintf("fetching JWT credentials failed with error: %v", err))
+		}
+		source = oauth2.ReuseTokenSource(nil, &skewTolerantSource{conf: conf})
+	}
+	client := oauth2.NewClient(ctx, source)
+	apiPacer.base = client.Transport
+	client.Transport = apiPacer
+	svc, err := drive.New(client)
+	if err != nil {
+		log.Println(err)
+	}
+	// Google APIs only compress responses for user agents containing gzip,
+	// which the transport then decompresses transparently
+	svc.UserAgent = "gdrive-upload-action (gzip)"
+	checkConnection(svc)
+	detectSharedDriveSupport(svc)
+	driveService = svc
+	driveClient = client
+	return svc
+}
+
+// destinationFolderId returns the id of the destination folder given by
+// folderId or folderProperty
+func destinationFolderId(svc *drive.Service) string {
+	if folderId := getInput(folderIdInput); folderId != "" {
+		return folderId
+	}
+	folderProperty := getInput(folderPropertyInput)
+	if folderProperty == "" {
+		missingInput(folderIdInput)
+	}
+	return findFolderByProperty(svc, folderProperty)
+}
+
+// findFolderByProperty returns the id of the only folder carrying the
+// appProperties marker given as key=value
+func findFolderByProperty(svc *drive.Service, marker string) string {
+	kv := strings.SplitN(marker, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		invalidInput(fmt.Sprintf("input '%v' must be of the form key=value, got '%v'", folderPropertyInput, marker))
+	}
+	q := fmt.Sprintf("appProperties has { key='%s' and value='%s' } and mimeType='application/vnd.google-apps.folder' and trashed=false", escapeQuery(kv[0]), escapeQuery(kv[1]))
+	r, err := svc.Files.List().Fields("files(name,id)").Q(q).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		fatalf(fmt.Sprintf("looking up folder with property %s failed with error: %v", marker, err))
+	}
+	switch len(r.Files) {
+	case 0:
+		fatalf(fmt.Sprintf("no folder with property %s found", marker))
+	case 1:
+		fmt.Printf("Using folder %s (%s) with property %s\n", r.Files[0].Name, r.Files[0].Id, marker)
+		return r.Files[0].Id
+	}
+	var ids []string
+	for _, f := range r.Files {
+		ids = append(ids, fmt.Sprintf("%s (%s)", f.Name, f.Id))
+	}
+	fatalf(fmt.Sprintf("more than one folder with property %s found: %s", marker, strings.Join(ids, ", ")))
+	return ""
+}
+
+// escapeQuery escapes a value used in a Drive search query string
+func escapeQuery(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+func findDriveDirectory(svc *drive.Service, folderId string, name string) string {
 	fmt.Printf("Checking for existing folder %s\n", name)
-	r, err := svc.Files.List().Fields("files(name,id,mimeType,parents)").Q("name='" + name + "'" + " and mimeType='application/vnd.google-apps.folder'").IncludeItemsFromAllDrives(true).Corpora("allDrives").SupportsAllDrives(true).Do()
+	r, err := svc.Files.List().Fields("files(name,id,mimeType,parents,createdTime)").Q(nameQuery(name) + " and mimeType='application/vnd.google-apps.folder' and trashed=false").IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
 	if err != nil {
-		log.Fatalf("Unable to check for folder : %v", err)
+		fatalf(fmt.Sprintf("Unable to check for folder : %v", err))
 		fmt.Println("Unable to check for folder")
 	}
-	foundFolders := 0
-	var nextFolderId string
+	// parallel jobs may have created the same folder more than once, always
+	// pick the oldest so that every job converges on the same folder
+	var next *drive.File
 	for _, i := range r.Files {
 		for _, p := range i.Parents {
 			if p == folderId {
-				foundFolders++
-				fmt.Printf("Found existing folder %s.\n", name)
-				nextFolderId = i.Id
+				fmt.Printf("Found existing folder %s (%s).\n", name, i.Id)
+				if next == nil || i.CreatedTime < next.CreatedTime || (i.CreatedTime == next.CreatedTime && i.Id < next.Id) {
+					next = i
+				}
 			}
 		}
 	}
-	if foundFolders == 0 {
+	if next == nil {
+		return ""
+	}
+	return next.Id
+}
+
+func createDriveDirectory(svc *drive.Service, folderId string, name string) (string, error) {
+	nextFolderId := findDriveDirectory(svc, folderId, name)
+	if nextFolderId == "" && folderCreateBackoff > 0 {
+		wait := time.Duration(rand.Int63n(int64(folderCreateBackoff)))
+		fmt.Printf("Waiting %v before creating folder %s\n", wait, name)
+		time.Sleep(wait)
+		nextFolderId = findDriveDirectory(svc, folderId, name)
+	}
+	if nextFolderId == "" {
 		fmt.Printf("Creating folder: %s\n", name)
 		f := &drive.File{
 			Name:     name,
-			MimeType: "application/vnd.google-apps.folder",
+			MimeType: folderMimeType,
 			Parents:  []string{folderId},
 		}
-		d, err := svc.Files.Create(f).Fields("id").SupportsAllDrives(true).Do()
+		d, err := svc.Files.Create(f).Fields("id").SupportsAllDrives(allDrives).Do()
 		if err != nil {
-			log.Fatalf("Unable to create folder : %v", err)
+			fatalf(fmt.Sprintf("Unable to create folder : %v", err))
 			fmt.Println("Unable to create folder")
 		}
 		nextFolderId = d.Id
+
+		// re-query to detect folders created concurrently by other jobs
+		if winner := findDriveDirectory(svc, folderId, name); winner != "" && winner != d.Id {
+			fmt.Printf("Folder %s was created concurrently, using %s instead of %s\n", name, winner, d.Id)
+			nextFolderId = winner
+			removeEmptyDirectory(svc, d.Id)
+		}
 	}
 	return nextFolderId, nil
 }
 
-func uploadFile(svc *drive.Service, filename string, folderId string, name string, mimeType string, overwriteFlag bool) {
+// removeEmptyDirectory deletes a duplicate folder created by this run, unless
+// another job already put something in it
+func removeEmptyDirectory(svc *drive.Service, folderId string) {
+	r, err := svc.Files.List().Fields("files(id)").Q("'" + folderId + "' in parents").PageSize(1).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("checking duplicate folder %s failed with error: %v", folderId, err))
+		return
+	}
+	if len(r.Files) > 0 {
+		githubactions.Warningf(fmt.Sprintf("duplicate folder %s is not empty, keeping it", folderId))
+		return
+	}
+	if err := svc.Files.Delete(folderId).SupportsAllDrives(allDrives).Do(); err != nil {
+		githubactions.Warningf(fmt.Sprintf("deleting duplicate folder %s failed with error: %v", folderId, err))
+	}
+}
 
-	fmt.Printf("target file name: %s\n", name)
+func findDriveFileInFolder(svc *drive.Service, folderId string, name string) *drive.File {
+	q := fmt.Sprintf("%s and '%s' in parents and trashed=false", nameQuery(name), escapeQuery(folderId))
+	r, err := svc.Files.List().Fields("files(name,id,mimeType,parents,version)").Q(q).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		fatalf(fmt.Sprintf("Unable to retrieve files: %v", err))
+	}
+	if len(r.Files) == 0 {
+		return nil
+	}
+	return r.Files[0]
+}
 
-	if overwriteFlag {
-		r, err := svc.Files.List().Fields("files(name,id,mimeType,parents)").Q("name='" + name + "'").IncludeItemsFromAllDrives(true).Corpora("allDrives").SupportsAllDrives(true).Do()
-		if err != nil {
-			log.Fatalf("Unable to retrieve files: %v", err)
-			fmt.Println("Unable to retrieve files")
-		}
-		fmt.Printf("Files: %d\n", len(r.Files))
-		var currentFile *drive.File = nil
-		for _, i := range r.Files {
-			found := false
-			if name == i.Name {
-				currentFile = i
-				for _, p := range i.Parents {
-					if p == folderId {
-						fmt.Println("file found in expected folder")
-						found = true
-						break
-					}
+func findDriveFile(svc *drive.Service, folderId string, name string) *drive.File {
+	r, err := svc.Files.List().Fields("files(name,id,mimeType,parents,version,md5Checksum,size,description,appProperties,modifiedTime)").Q(nameQuery(name)).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		fatalf(fmt.Sprintf("Unable to retrieve files: %v", err))
+		fmt.Println("Unable to retrieve files")
+	}
+	fmt.Printf("Files: %d\n", len(r.Files))
+	var currentFile *drive.File = nil
+	for _, i := range r.Files {
+		found := false
+		if nfc(name) == nfc(i.Name) {
+			currentFile = i
+			for _, p := range i.Parents {
+				if p == folderId {
+					fmt.Println("file found in expected folder")
+					found = true
+					break
 				}
 			}
-			if found {
-				break
-			}
 		}
-
-		if currentFile == nil {
-			fmt.Println("No similar files found. Creating a new file")
-			uploadToDrive(svc, filename, folderId, nil, name, mimeType)
-		} else {
-			fmt.Printf("Overwriting file: %s (%s)\n", currentFile.Name, currentFile.Id)
-			uploadToDrive(svc, filename, folderId, currentFile, name, mimeType)
+		if found {
+			break
 		}
-	} else {
-		uploadToDrive(svc, filename, folderId, nil, name, mimeType)
 	}
+	return currentFile
+}
+
+func getBoolInput(inputName string) bool {
+	value := getInput(inputName)
+	if value == "" {
+		return false
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		invalidInput(fmt.Sprintf("input '%v' must be a boolean, got '%v'", inputName, value))
+	}
+	return flag
 }
 
 func missingInput(inputName string) {
-	githubactions.Fatalf(fmt.Sprintf("missing input '%v'", inputName))
+	invalidInput(fmt.Sprintf("missing input '%v'", inputName))
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const manifestNameInput = "manifestName"
+
+// manifestEntry maps the name of an uploaded file to where and what was
+// stored in Google Drive
+type manifestEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+	FileId string `json:"fileId"`
+	// ParentId is the id of the folder the file is in
+	ParentId string `json:"parentId,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Sha256   string `json:"sha256,omitempty"`
+	Md5      string `json:"md5,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Uid      string `json:"uid,omitempty"`
+	Gid      string `json:"gid,omitempty"`
+	// Scan is the result of the virus scan of the source, if enabled
+	Scan string `json:"scan,omitempty"`
+	// Action is what the run did with the file, e.g. create or keep
+	Action string `json:"-"`
+}
+
+// manifest describes the files published by a run
+type manifest struct {
+	FolderId   string            `json:"folderId"`
+	Repository string            `json:"repository,omitempty"`
+	Sha        string            `json:"sha,omitempty"`
+	RunId      string            `json:"runId,omitempty"`
+	Created    string            `json:"created"`
+	Config     map[string]string `json:"config,omitempty"`
+	// RootDigest is the merkle root of the files, if rootDigest is enabled
+	RootDigest string `json:"rootDigest,omitempty"`
+	// Placements are the files placed in each destination, if checkFreeSpace
+	// is enabled
+	Placements []placement     `json:"placements,omitempty"`
+	Files      []manifestEntry `json:"files"`
+}
+
+var runManifest = &manifest{Files: []manifestEntry{}}
+
+func (m *manifest) add(op operation, fileId string) {
+	name := op.LogicalPath
+	if name == "" {
+		name = op.Path
+	}
+	m.Files = append(m.Files, manifestEntry{
+		Name:     name,
+		Path:     op.Path,
+		Source:   op.Source,
+		FileId:   fileId,
+		ParentId: op.ParentId,
+		Size:     op.Size,
+		Sha256:   op.Sha256,
+		Md5:      op.Md5,
+		Mode:     op.Mode,
+		Uid:      op.Uid,
+		Gid:      op.Gid,
+		Scan:     op.Scan,
+		Action:   op.Action,
+	})
+}
+
+// uploadManifest uploads the manifest of the run to the destination folder,
+// updating the manifest of the same name if there is one
+func uploadManifest(svc *drive.Service, pl *plan) {
+	if pl.Manifest == "" {
+		return
+	}
+	runManifest.FolderId = pl.FolderId
+	runManifest.Repository = os.Getenv("GITHUB_REPOSITORY")
+	runManifest.Sha = os.Getenv("GITHUB_SHA")
+	runManifest.RunId = os.Getenv("GITHUB_RUN_ID")
+	runManifest.Created = time.Now().UTC().Format(time.RFC3339)
+	runManifest.Config = resolvedConfig()
+	data, err := json.MarshalIndent(runManifest, "", "  ")
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding manifest failed with error: %v", err))
+	}
+	sum := sha256.Sum256(data)
+	manifestDigest = hex.EncodeToString(sum[:])
+	tmp, err := os.CreateTemp(tempDir, "gdrive-upload-manifest-*.json")
+	if err != nil {
+		fatalf(fmt.Sprintf("writing manifest failed with error: %v", err))
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	tmp.Close()
+
+	op := operation{Action: actionCreate, Path: pl.Manifest, Name: pl.Manifest, Source: tmp.Name()}
+	existing := findDriveFileInFolder(svc, pl.FolderId, pl.Manifest)
+	if existing != nil {
+		op.Action = actionUpdate
+	}
+	fmt.Printf("Uploading manifest %s with %d file(s)\n", pl.Manifest, len(runManifest.Files))
+	if _, err := uploadToDrive(svc, tmp.Name(), pl.FolderId, existing, pl.Manifest, "application/json", "", nil); err != nil {
+		op.Source = "manifest"
+		recordFailure(op, err)
+	}
+}
+
+func fileLink(id string) string {
+	return "https://drive.google.com/file/d/" + id + "/view"
+}
+
+// fileOutput is an element of the 'files' output
+type fileOutput struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Source   string `json:"source,omitempty"`
+	FileId   string `json:"fileId"`
+	FolderId string `json:"folderId,omitempty"`
+	Link     string `json:"link"`
+}
+
+// outputFiles exposes the files of the run as the 'files' output, and when
+// exactly one file was uploaded, also as the flat 'fileId', 'fileName',
+// 'link' and 'folderId' outputs. Kept files and metadata updates do not
+// count as uploaded.
+func outputFiles() {
+	files := []fileOutput{}
+	var uploaded []fileOutput
+	for _, e := range runManifest.Files {
+		f := fileOutput{
+			Name:     path.Base(e.Path),
+			Path:     e.Path,
+			Source:   e.Source,
+			FileId:   e.FileId,
+			FolderId: e.ParentId,
+			Link:     fileLink(e.FileId),
+		}
+		files = append(files, f)
+		if e.Action == actionCreate || e.Action == actionUpdate {
+			uploaded = append(uploaded, f)
+		}
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding files failed with error: %v", err))
+	}
+	githubactions.SetOutput("files", string(data))
+	if len(uploaded) == 1 {
+		githubactions.SetOutput("fileId", uploaded[0].FileId)
+		githubactions.SetOutput("fileName", uploaded[0].Name)
+		githubactions.SetOutput("link", uploaded[0].Link)
+		githubactions.SetOutput("folderId", uploaded[0].FolderId)
+	}
+}
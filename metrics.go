@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const metricsFileInput = "metricsFile"
+
+// runStats accumulates the upload statistics of a run
+type runStats struct {
+	start    time.Time
+	files    map[string]int64
+	bytes    int64
+	duration time.Duration
+}
+
+var stats = &runStats{start: time.Now(), files: map[string]int64{}}
+
+// metricsFile is the path the Prometheus metrics are written to
+var metricsFile string
+
+func (s *runStats) record(size int64, duration time.Duration, err error) {
+	if err != nil {
+		s.files["failure"]++
+	} else {
+		s.files["success"]++
+		s.bytes += size
+	}
+	s.duration += duration
+}
+
+func metricLabels(extra ...string) string {
+	labels := extra
+	for _, env := range []string{"GITHUB_REPOSITORY", "GITHUB_WORKFLOW"} {
+		if v := os.Getenv(env); v != "" {
+			name := strings.ToLower(strings.TrimPrefix(env, "GITHUB_"))
+			labels = append(labels, fmt.Sprintf("%s=%q", name, v))
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// writeMetricsFile writes the statistics of the run in the Prometheus text
+// format, e.g. for the node-exporter textfile collector. The file is replaced
+// atomically so that it is never scraped half-written.
+func writeMetricsFile(path string) {
+	if path == "" {
+		return
+	}
+	var b bytes.Buffer
+	gauge := func(name string, help string, values map[string]string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		var keys []string
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s%s %s\n", name, k, values[k])
+		}
+	}
+	files := map[string]string{}
+	for _, result := range []string{"success", "failure"} {
+		files[metricLabels(fmt.Sprintf("result=%q", result))] = fmt.Sprint(stats.files[result])
+	}
+	labels := metricLabels()
+	gauge("gdrive_upload_files", "Number of files uploaded by the last run, by result.", files)
+	gauge("gdrive_upload_failures", "Number of files that failed to upload in the last run.", map[string]string{labels: fmt.Sprint(stats.files["failure"])})
+	gauge("gdrive_upload_bytes", "Number of bytes uploaded by the last run.", map[string]string{labels: fmt.Sprint(stats.bytes)})
+	gauge("gdrive_upload_duration_seconds", "Time spent uploading files in the last run.", map[string]string{labels: fmt.Sprint(stats.duration.Seconds())})
+	gauge("gdrive_upload_run_duration_seconds", "Duration of the last run.", map[string]string{labels: fmt.Sprint(time.Since(stats.start).Seconds())})
+	gauge("gdrive_upload_last_run_timestamp_seconds", "Unix time the last run finished.", map[string]string{labels: fmt.Sprint(time.Now().Unix())})
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gdrive-upload-metrics-*")
+	if err == nil {
+		_, err = tmp.Write(b.Bytes())
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), 0644)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing metrics to %s failed with error: %v", path, err))
+		return
+	}
+	fmt.Printf("Metrics written to %s\n", path)
+}
+
+// report exposes the totals of the run as the 'uploadedFiles',
+// 'uploadedBytes' and 'uploadSeconds' outputs, and adds them to the job
+// summary
+func (s *runStats) report() {
+	githubactions.SetOutput("uploadedFiles", fmt.Sprint(s.files["success"]))
+	githubactions.SetOutput("uploadedBytes", fmt.Sprint(s.bytes))
+	githubactions.SetOutput("uploadSeconds", fmt.Sprintf("%.3f", s.duration.Seconds()))
+	elapsed := time.Since(s.start)
+	fmt.Printf("Uploaded %d file(s), %s in %s, %d failed, run took %s\n", s.files["success"], formatBytes(s.bytes), formatDuration(s.duration), s.files["failure"], formatDuration(elapsed))
+	var b strings.Builder
+	b.WriteString("### Google Drive upload\n\n")
+	b.WriteString("| Uploaded | Size | Upload time | Failed | Run time |\n|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %s | %s | %d | %s |\n\n", s.files["success"], formatBytes(s.bytes), formatDuration(s.duration), s.files["failure"], formatDuration(elapsed))
+	appendStepSummary(b.String())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	onMissingInput = "onMissing"
+	onMissingFail  = "fail"
+	onMissingSkip  = "skip"
+)
+
+var onMissing = onMissingFail
+
+// missingFiles are the matched files that disappeared before being uploaded
+// and were skipped
+var missingFiles []string
+
+func parseOnMissing() {
+	switch v := getInput(onMissingInput); v {
+	case "":
+	case onMissingFail, onMissingSkip:
+		onMissing = v
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", onMissingInput, onMissingFail, onMissingSkip, v))
+	}
+}
+
+// skipMissing reports whether the file of an operation disappeared since it
+// was matched and is skipped, e.g. a temporary file removed by a concurrent
+// step. Missing files fail as any other error unless onMissing is skip.
+func skipMissing(op operation) bool {
+	if onMissing != onMissingSkip || op.Source == stdinFilename {
+		return false
+	}
+	if _, err := os.Lstat(op.content()); !os.IsNotExist(err) {
+		return false
+	}
+	for _, f := range missingFiles {
+		if f == op.Source {
+			return true
+		}
+	}
+	githubactions.Warningf(fmt.Sprintf("%s disappeared since it was matched, skipping it", op.Source))
+	missingFiles = append(missingFiles, op.Source)
+	return true
+}
+
+// outputMissingFiles exposes the skipped missing files as the 'missingFiles'
+// output and lists them in the job summary
+func outputMissingFiles() {
+	if onMissing != onMissingSkip {
+		return
+	}
+	data, err := json.Marshal(append([]string{}, missingFiles...))
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding missing files failed with error: %v", err))
+	}
+	githubactions.SetOutput("missingFiles", string(data))
+	if len(missingFiles) == 0 {
+		return
+	}
+	fmt.Printf("%d file(s) disappeared before being uploaded and were skipped\n", len(missingFiles))
+	appendStepSummary("### Missing files\n\nThese files disappeared before being uploaded and were skipped:\n\n- `" + strings.Join(missingFiles, "`\n- `") + "`\n\n")
+}
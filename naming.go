@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameData is the data available to name templates
+type nameData struct {
+	Branch     string
+	Sha        string
+	Ref        string
+	Repository string
+	Workflow   string
+	RunId      string
+	RunNumber  string
+	Path       string
+	Base       string
+	Ext        string
+	Date       time.Time
+	// Match are the named capture groups of namePattern in the source path
+	Match map[string]string
+}
+
+// CommitMessage is the message of the triggering commit, only looked up when
+// a template uses it
+func (nameData) CommitMessage() string {
+	return commitMessage()
+}
+
+const namePatternInput = "namePattern"
+
+// namePattern is matched against the source paths, so that name templates
+// can reference its named capture groups
+var namePattern *regexp.Regexp
+
+func parseNamePattern() {
+	v := getInput(namePatternInput)
+	if v == "" {
+		return
+	}
+	re, err := regexp.Compile(v)
+	if err != nil {
+		invalidInput(fmt.Sprintf("invalid regular expression for input '%v': %v", namePatternInput, err))
+	}
+	if len(re.SubexpNames()) < 2 {
+		invalidInput(fmt.Sprintf("input '%v' has no capture group", namePatternInput))
+	}
+	namePattern = re
+}
+
+// matchGroups returns the named capture groups of namePattern in the path of
+// a source file, or nil when it does not match
+func matchGroups(file string) map[string]string {
+	if namePattern == nil || file == stdinFilename {
+		return nil
+	}
+	m := namePattern.FindStringSubmatch(normalizePath(file))
+	if m == nil {
+		return nil
+	}
+	groups := map[string]string{}
+	for i, name := range namePattern.SubexpNames() {
+		if name != "" {
+			groups[name] = m[i]
+		}
+	}
+	return groups
+}
+
+var unsafeNameChars = regexp.MustCompile(`[/\\:*?"<>|\s]+`)
+
+var nameFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"sanitize":   func(s string) string { return strings.Trim(unsafeNameChars.ReplaceAllString(s, "-"), "-") },
+}
+
+var runDate = time.Now().UTC()
+
+func newNameData(file string) nameData {
+	branch := os.Getenv("GITHUB_HEAD_REF")
+	if branch == "" {
+		branch = strings.TrimPrefix(strings.TrimPrefix(os.Getenv("GITHUB_REF"), "refs/heads/"), "refs/tags/")
+	}
+	d := nameData{
+		Branch:     branch,
+		Sha:        os.Getenv("GITHUB_SHA"),
+		Ref:        os.Getenv("GITHUB_REF"),
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		Workflow:   os.Getenv("GITHUB_WORKFLOW"),
+		RunId:      os.Getenv("GITHUB_RUN_ID"),
+		RunNumber:  os.Getenv("GITHUB_RUN_NUMBER"),
+		Date:       runDate,
+	}
+	if file != stdinFilename {
+		d.Path = file
+		d.Base = filepath.Base(file)
+		d.Ext = filepath.Ext(file)
+		d.Match = matchGroups(file)
+	}
+	return d
+}
+
+// expandName renders the template in the value of the given input for a
+// source file. Values without template actions are returned unchanged.
+func expandName(input string, value string, file string) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+	t, err := template.New(input).Funcs(nameFuncs).Option("missingkey=error").Parse(value)
+	if err != nil {
+		fatalf(fmt.Sprintf("parsing template of input '%s' failed with error: %v", input, err))
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, newNameData(file)); err != nil {
+		fatalf(fmt.Sprintf("executing template of input '%s' failed with error: %v", input, err))
+	}
+	return b.String()
+}
+
+// nfc normalizes a name to the NFC form names are uploaded and matched in
+func nfc(name string) string {
+	return norm.NFC.String(name)
+}
+
+// nameQuery is a Drive search query clause matching a name in both its NFC
+// and NFD forms, since files uploaded from macOS may be named in NFD
+func nameQuery(name string) string {
+	c, d := norm.NFC.String(name), norm.NFD.String(name)
+	if c == d {
+		return "name='" + escapeQuery(c) + "'"
+	}
+	return "(name='" + escapeQuery(c) + "' or name='" + escapeQuery(d) + "')"
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	sharedDriveMaintenanceInput = "sharedDriveMaintenance"
+	sharedDriveIdInput          = "sharedDriveId"
+
+	maintenanceMembers         = "members"
+	maintenancePendingDeletion = "pendingDeletion"
+	maintenanceMove            = "move"
+
+	// trashRetention is how long Google Drive keeps trashed items of shared
+	// drives before deleting them forever
+	trashRetention = 30 * 24 * time.Hour
+)
+
+// sharedDriveMember is an element of the 'sharedDriveMembers' output
+type sharedDriveMember struct {
+	Type   string `json:"type"`
+	Email  string `json:"emailAddress,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Role   string `json:"role"`
+}
+
+// pendingDeletion is an element of the 'pendingDeletion' output, a trashed
+// item of a shared drive and when it is deleted forever
+type pendingDeletion struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size,omitempty"`
+	TrashedBy   string `json:"trashedBy,omitempty"`
+	TrashedTime string `json:"trashedTime"`
+	DeletedTime string `json:"deletedTime"`
+}
+
+// checkSharedDriveMaintenance fails early on an unknown maintenance
+// operation or a missing shared drive
+func checkSharedDriveMaintenance(maintenance string) string {
+	switch maintenance {
+	case maintenanceMembers, maintenancePendingDeletion, maintenanceMove:
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be %v, %v or %v, got '%v'", sharedDriveMaintenanceInput, maintenanceMembers, maintenancePendingDeletion, maintenanceMove, maintenance))
+	}
+	driveId := getInput(sharedDriveIdInput)
+	if driveId == "" {
+		missingInput(sharedDriveIdInput)
+	}
+	return driveId
+}
+
+// sharedDriveMembers exposes the members of a shared drive and their role as
+// the 'sharedDriveMembers' output
+func sharedDriveMembers(svc *drive.Service, driveId string) {
+	permissions, err := listPermissions(svc, driveId)
+	if err != nil {
+		fatalf(fmt.Sprintf("listing members of shared drive %s failed with error: %v", driveId, err))
+	}
+	members := []sharedDriveMember{}
+	for _, p := range permissions {
+		members = append(members, sharedDriveMember{Type: p.Type, Email: p.EmailAddress, Domain: p.Domain, Role: p.Role})
+		who := p.EmailAddress + p.Domain
+		if who == "" {
+			who = p.Type
+		}
+		fmt.Printf("  %s: %s\n", who, p.Role)
+	}
+	fmt.Printf("Shared drive %s has %d member(s)\n", driveId, len(members))
+	data, err := json.Marshal(members)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding members failed with error: %v", err))
+	}
+	githubactions.SetOutput("sharedDriveMembers", string(data))
+}
+
+// reportPendingDeletion exposes the trashed items of a shared drive, which
+// are deleted forever once they were in the trash for 30 days, as the
+// 'pendingDeletion' output, the soonest deleted first
+func reportPendingDeletion(svc *drive.Service, driveId string) {
+	items := []pendingDeletion{}
+	pageToken := ""
+	for {
+		r, err := svc.Files.List().Fields("nextPageToken,files(id,name,size,trashedTime,trashingUser(emailAddress))").Q("trashed=true").Corpora("drive").DriveId(driveId).IncludeItemsFromAllDrives(true).SupportsAllDrives(true).PageToken(pageToken).PageSize(1000).Do()
+		if err != nil {
+			fatalf(fmt.Sprintf("listing the trash of shared drive %s failed with error: %v", driveId, err))
+		}
+		for _, f := range r.Files {
+			item := pendingDeletion{Id: f.Id, Name: f.Name, Size: f.Size, TrashedTime: f.TrashedTime}
+			if f.TrashingUser != nil {
+				item.TrashedBy = f.TrashingUser.EmailAddress
+			}
+			if t, err := time.Parse(time.RFC3339, f.TrashedTime); err == nil {
+				item.DeletedTime = t.Add(trashRetention).UTC().Format(time.RFC3339)
+			}
+			items = append(items, item)
+		}
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedTime < items[j].DeletedTime })
+	for _, item := range items {
+		fmt.Printf("  %s (%s): trashed by %s, deleted on %s\n", item.Name, item.Id, item.TrashedBy, item.DeletedTime)
+	}
+	fmt.Printf("%d item(s) of shared drive %s pending deletion\n", len(items), driveId)
+	data, err := json.Marshal(items)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding pending deletions failed with error: %v", err))
+	}
+	githubactions.SetOutput("pendingDeletion", string(data))
+}
+
+// moveSharedDriveContent moves the top-level files and folders of a shared
+// drive into the destination folder, e.g. in another shared drive, keeping
+// their ids, links and revisions. Moving folders between shared drives needs
+// the organizer role on both.
+func moveSharedDriveContent(svc *drive.Service, driveId string, folderId string, dryRun bool) {
+	children, err := listChildren(svc, driveId, "id,name,mimeType")
+	if err != nil {
+		fatalf(fmt.Sprintf("listing shared drive %s failed with error: %v", driveId, err))
+	}
+	fmt.Printf("Moving %d item(s) of shared drive %s to folder %s\n", len(children), driveId, folderId)
+	moved := 0
+	for _, f := range children {
+		if dryRun {
+			fmt.Printf("  would move %s (%s)\n", f.Name, f.Id)
+			continue
+		}
+		op := operation{Action: "move", Path: f.Name, Name: f.Name, Source: f.Id, ParentId: folderId}
+		if _, err := svc.Files.Update(f.Id, &drive.File{}).AddParents(folderId).RemoveParents(driveId).Fields("id").SupportsAllDrives(true).Do(); err != nil {
+			recordFailure(op, fmt.Errorf("moving %s (%s) failed with error: %w", f.Name, f.Id, err))
+			continue
+		}
+		fmt.Printf("Moved %s (%s)\n", f.Name, f.Id)
+		moved++
+	}
+	if dryRun {
+		fmt.Println("Dry run enabled. Nothing was moved.")
+	}
+	githubactions.SetOutput("movedFiles", fmt.Sprint(moved))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	ownershipReportInput = "ownershipReport"
+	expectedOwnerInput   = "expectedOwner"
+)
+
+// ownershipIssue is a file of the destination subtree not owned as expected
+type ownershipIssue struct {
+	Id     string `json:"id"`
+	Path   string `json:"path"`
+	Owner  string `json:"owner,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// listChildren returns the files and folders in a folder
+func listChildren(svc *drive.Service, folderId string, fields string) ([]*drive.File, error) {
+	var files []*drive.File
+	q := fmt.Sprintf("'%s' in parents and trashed=false", escapeQuery(folderId))
+	pageToken := ""
+	for {
+		r, err := svc.Files.List().Fields(googleapi.Field("nextPageToken,files(" + fields + ")")).Q(q).PageToken(pageToken).PageSize(1000).IncludeItemsFromAllDrives(allDrives).Corpora(corpora()).SupportsAllDrives(allDrives).Do()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, r.Files...)
+		if r.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ownershipProblem returns the owner of a file and why it is not owned as
+// expected, or empty strings. Files in shared drives are owned by the drive and have no owner.
+func ownershipProblem(f *drive.File, expectedOwner string) (string, string) {
+	for _, p := range f.Permissions {
+		if p.Role == "owner" && p.Deleted {
+			return p.EmailAddress, "owner account was deleted"
+		}
+	}
+	for _, o := range f.Owners {
+		if expectedOwner == "" && o.Me || strings.EqualFold(o.EmailAddress, expectedOwner) {
+			return "", ""
+		}
+	}
+	if len(f.Owners) == 0 {
+		return "", ""
+	}
+	owner := f.Owners[0].EmailAddress
+	if owner == "" {
+		owner = f.Owners[0].DisplayName
+	}
+	return owner, "not owned by the expected owner"
+}
+
+// ownershipReport scans the subtree of the destination folder for files not
+// owned by the expected owner, the service account by default, or owned by
+// deleted accounts, and exposes them as the 'ownershipIssues' output
+func ownershipReport(svc *drive.Service, folderId string) {
+	expectedOwner := getInput(expectedOwnerInput)
+	who := expectedOwner
+	if who == "" {
+		who = "the service account"
+	}
+	fmt.Printf("Checking that the files in folder %s are owned by %s\n", folderId, who)
+	issues := []ownershipIssue{}
+	scanned := 0
+	err := walkTree(svc, folderId, "id,name,mimeType,owners(emailAddress,displayName,me),permissions(role,emailAddress,deleted)", func(folderPath string, f *drive.File) {
+		scanned++
+		p := remotePath(folderPath, f.Name)
+		if owner, reason := ownershipProblem(f, expectedOwner); reason != "" {
+			fmt.Printf("  %s (%s): %s, owned by %s\n", p, f.Id, reason, owner)
+			issues = append(issues, ownershipIssue{Id: f.Id, Path: p, Owner: owner, Reason: reason})
+		}
+	})
+	if err != nil {
+		fatalf(err.Error())
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	fmt.Printf("%d of %d file(s) not owned as expected\n", len(issues), scanned)
+	data, err := json.Marshal(issues)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding ownership issues failed with error: %v", err))
+	}
+	githubactions.SetOutput("ownershipIssues", string(data))
+}
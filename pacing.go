@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	requestsPer100SecondsInput = "requestsPer100Seconds"
+	maxRateLimitRetries        = 5
+)
+
+// throttleEvent is a time the upload was slowed down, either by pacing
+// before reaching the request quota or by Drive rejecting a request
+type throttleEvent struct {
+	Time   string  `json:"time"`
+	Kind   string  `json:"kind"`
+	Wait   float64 `json:"waitSeconds"`
+	Status int     `json:"status,omitempty"`
+}
+
+// pacer counts the Drive API requests of the run and, when a quota is given,
+// delays requests to stay below it
+type pacer struct {
+	// mu serializes the accounting of concurrent requests
+	mu       sync.Mutex
+	base     http.RoundTripper
+	limit    int
+	window   time.Duration
+	sent     []time.Time
+	requests int
+	// retries are the requests sent again after being rate limited
+	retries int
+	events  []throttleEvent
+}
+
+var apiPacer = &pacer{window: 100 * time.Second}
+
+func parsePacingInputs() {
+	if v := getInput(requestsPer100SecondsInput); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			invalidInput(fmt.Sprintf("input '%v' must be a positive number, got '%v'", requestsPer100SecondsInput, v))
+		}
+		apiPacer.limit = n
+	}
+	if v := getInput(listConcurrencyInput); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			invalidInput(fmt.Sprintf("input '%v' must be a positive number, got '%v'", listConcurrencyInput, v))
+		}
+		listConcurrency = n
+	}
+}
+
+// wait delays the next request while the requests of the last window reach
+// 90% of the quota, leaving room for other users of the same account.
+// Concurrent requests wait for each other while paused.
+func (p *pacer) wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if p.limit == 0 {
+		return
+	}
+	threshold := p.limit * 9 / 10
+	if threshold == 0 {
+		threshold = 1
+	}
+	now := time.Now()
+	for len(p.sent) > 0 && now.Sub(p.sent[0]) >= p.window {
+		p.sent = p.sent[1:]
+	}
+	if len(p.sent) >= threshold {
+		d := p.window - now.Sub(p.sent[len(p.sent)-threshold])
+		if d < 10*time.Millisecond {
+			d = 10 * time.Millisecond
+		}
+		fmt.Printf("Approaching %d requests per 100 seconds, pausing for %s\n", p.limit, formatDuration(d))
+		p.events = append(p.events, throttleEvent{Time: now.UTC().Format(time.RFC3339), Kind: "paced", Wait: d.Seconds()})
+		time.Sleep(d)
+	}
+	p.sent = append(p.sent, time.Now())
+}
+
+// isRateLimited reports whether Drive rejected a response for exceeding a
+// quota, restoring its body
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return bytes.Contains(body, []byte("rateLimitExceeded")) || bytes.Contains(body, []byte("userRateLimitExceeded"))
+}
+
+// RoundTrip paces requests, and retries requests rejected for exceeding a
+// quota with an exponential backoff when their body can be sent again. The
+// request of the caller is never modified, each retry sends a clone of it.
+func (p *pacer) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		p.wait()
+		r := req
+		if attempt > 0 {
+			r = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		resp, err := p.base.RoundTrip(r)
+		if err != nil || !isRateLimited(resp) {
+			return resp, err
+		}
+		canRetry := req.Body == nil || req.GetBody != nil
+		event := throttleEvent{Time: time.Now().UTC().Format(time.RFC3339), Kind: "rateLimited", Status: resp.StatusCode}
+		if attempt == maxRateLimitRetries || !canRetry {
+			p.record(event)
+			return resp, nil
+		}
+		event.Wait = backoff.Seconds()
+		p.record(event)
+		p.mu.Lock()
+		p.retries++
+		p.mu.Unlock()
+		resp.Body.Close()
+		fmt.Printf("Rate limited by Google Drive, retrying in %s\n", formatDuration(backoff))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryCount returns the number of requests sent again so far
+func (p *pacer) retryCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.retries
+}
+
+func (p *pacer) record(event throttleEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+// report exposes the throttle events as the 'throttleEvents' output and adds
+// the API usage of the run to the job summary
+func (p *pacer) report() {
+	data, err := json.Marshal(append([]throttleEvent{}, p.events...))
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding throttle events failed with error: %v", err))
+	}
+	githubactions.SetOutput("throttleEvents", string(data))
+
+	counts := map[string]int{}
+	var waited float64
+	for _, e := range p.events {
+		counts[e.Kind]++
+		waited += e.Wait
+	}
+	fmt.Printf("%d Drive API request(s), paced %d time(s), rate limited %d time(s)\n", p.requests, counts["paced"], counts["rateLimited"])
+	var b strings.Builder
+	b.WriteString("### Google Drive API usage\n\n")
+	b.WriteString("| Requests | Paced | Rate limited | Waited |\n|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %s |\n\n", p.requests, counts["paced"], counts["rateLimited"], formatDuration(time.Duration(waited*float64(time.Second))))
+	appendStepSummary(b.String())
+}
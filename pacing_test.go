@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestPacerRetriesWithClones(t *testing.T) {
+	var sent []*http.Request
+	var bodies []string
+	p := &pacer{window: 100, base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req)
+		data, _ := io.ReadAll(req.Body)
+		req.Body.Close()
+		bodies = append(bodies, string(data))
+		status := http.StatusOK
+		if len(sent) == 1 {
+			status = http.StatusTooManyRequests
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	})}
+	req, err := http.NewRequest("POST", "https://www.googleapis.com/upload/drive/v3/files", bytes.NewReader([]byte("content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+	resp, err := p.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(sent) != 2 || bodies[0] != "content" || bodies[1] != "content" {
+		t.Fatalf("sent %d request(s) with bodies %q, want 2 with the content", len(sent), bodies)
+	}
+	if sent[0] != req || sent[1] == req {
+		t.Error("the retry did not send a clone of the request")
+	}
+	if req.Body != body {
+		t.Error("the body of the request of the caller was replaced")
+	}
+	if p.retryCount() != 1 {
+		t.Errorf("retryCount() = %d, want 1", p.retryCount())
+	}
+}
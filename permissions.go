@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	recordPermissionsInput = "recordPermissions"
+	recordOwnershipInput   = "recordOwnership"
+)
+
+var recordPermissions, recordOwnership bool
+
+// recordMode sets the mode bits, and the owner if enabled, of the source file
+// of an operation so that they can be restored when downloading it
+func (op *operation) recordMode() {
+	if !recordPermissions || op.Source == stdinFilename {
+		return
+	}
+	fi, err := os.Stat(op.Source)
+	if err != nil {
+		return
+	}
+	mode := uint32(fi.Mode().Perm())
+	if fi.Mode()&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if fi.Mode()&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if fi.Mode()&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	op.Mode = fmt.Sprintf("%04o", mode)
+	if recordOwnership {
+		if uid, gid, ok := fileOwner(fi); ok {
+			op.Uid, op.Gid = fmt.Sprint(uid), fmt.Sprint(gid)
+		}
+	}
+}
+
+// appProperties are the private properties stored with the uploaded file
+func (op operation) appProperties() map[string]string {
+	if op.Mode == "" && op.Revision == "" && provenance == nil {
+		return nil
+	}
+	props := map[string]string{}
+	for k, v := range provenance {
+		props[k] = v
+	}
+	if op.Mode != "" {
+		props["mode"] = op.Mode
+	}
+	if op.Uid != "" {
+		props["uid"] = op.Uid
+		props["gid"] = op.Gid
+	}
+	if op.Revision != "" {
+		props[revisionProperty] = op.Revision
+	}
+	return props
+}
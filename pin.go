@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	pinToFolderInput = "pinToFolder"
+	// pinProperty marks the shortcuts pinned by the action with the id of
+	// the destination folder of the pinned files
+	pinProperty = "gdriveUploadPin"
+)
+
+// pinUploads keeps shortcuts to the files uploaded by the run in the folder
+// given by pinToFolder, e.g. a "Latest builds" folder, and removes the
+// shortcuts pinned by earlier runs to the same destination
+func pinUploads(svc *drive.Service, pl *plan) {
+	pinFolder := getInput(pinToFolderInput)
+	if pinFolder == "" || len(runManifest.Files) == 0 {
+		return
+	}
+	op := operation{Action: actionShortcut, Path: pinFolder, Source: "pin"}
+	children, err := listChildren(svc, pinFolder, "id,name,shortcutDetails,appProperties")
+	if err != nil {
+		recordFailure(op, fmt.Errorf("listing folder %s failed with error: %w", pinFolder, err))
+		return
+	}
+	pinned := map[string]bool{}
+	for _, c := range children {
+		if c.AppProperties[pinProperty] == pl.FolderId && c.ShortcutDetails != nil {
+			pinned[c.ShortcutDetails.TargetId] = true
+		}
+	}
+	current := map[string]bool{}
+	for _, f := range runManifest.Files {
+		current[f.FileId] = true
+		if pinned[f.FileId] {
+			continue
+		}
+		op.Path = remotePath(pinFolder, path.Base(f.Name))
+		if _, err := createShortcut(svc, pinFolder, path.Base(f.Name), f.FileId, map[string]string{pinProperty: pl.FolderId}); err != nil {
+			recordFailure(op, err)
+		}
+	}
+	for _, c := range children {
+		if c.AppProperties[pinProperty] != pl.FolderId || c.ShortcutDetails == nil || current[c.ShortcutDetails.TargetId] {
+			continue
+		}
+		fmt.Printf("Removing stale shortcut %s (%s)\n", c.Name, c.Id)
+		if err := svc.Files.Delete(c.Id).SupportsAllDrives(allDrives).Do(); err != nil {
+			op.Path = remotePath(pinFolder, c.Name)
+			recordFailure(op, fmt.Errorf("removing stale shortcut failed with error: %w", err))
+		}
+	}
+}
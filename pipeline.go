@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const pipelineDepthInput = "pipelineDepth"
+
+// preparedFile is the outcome of transforming and hashing a file ahead of
+// planning it
+type preparedFile struct {
+	done   chan struct{}
+	suffix string
+	// hashed is the path of the hashed content, empty if not hashed
+	hashed string
+	hash   fileHash
+	err    error
+}
+
+// preparePipeline transforms and hashes the next files in the background
+// while the current ones are planned. Uploads only start once the whole plan
+// is built, so preparation overlaps with the lookups of planning, not with
+// the uploads. At most depth files are prepared ahead, so that slow lookups
+// hold back the preparation instead of letting transformed copies pile up.
+type preparePipeline struct {
+	mu       sync.Mutex
+	files    map[string]*preparedFile
+	byHashed map[string]*preparedFile
+	slots    chan struct{}
+	released map[string]bool
+	hashing  bool
+	// reusable are the files whose hash the index already knows
+	reusable map[string]bool
+}
+
+// pipeline is nil unless files are prepared ahead
+var pipeline *preparePipeline
+
+// parsePipelineDepth returns the number of files prepared ahead. Shell
+// transforms are not run concurrently unless asked for, as commands written
+// for one file at a time may share temporary files.
+func parsePipelineDepth(transforms []transformRule) int {
+	v := getInput(pipelineDepthInput)
+	if v == "" {
+		for _, t := range transforms {
+			if strings.HasPrefix(t.transform, shellTransform) {
+				return 1
+			}
+		}
+		return runtime.NumCPU()
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		invalidInput(fmt.Sprintf("input '%v' must be a number of files, got '%v'", pipelineDepthInput, v))
+	}
+	return n
+}
+
+// startPipeline prepares the files in order in the background. Files are
+// hashed when hash is set, unless the index already knows their hash.
+func startPipeline(files []string, transforms []transformRule, hash bool) {
+	depth := parsePipelineDepth(transforms)
+	if depth == 0 || (!hash && len(transforms) == 0) {
+		return
+	}
+	p := &preparePipeline{
+		files:    map[string]*preparedFile{},
+		byHashed: map[string]*preparedFile{},
+		slots:    make(chan struct{}, depth),
+		released: map[string]bool{},
+		hashing:  hash,
+		reusable: map[string]bool{},
+	}
+	var queued []string
+	for _, file := range files {
+		if file == stdinFilename || p.files[file] != nil {
+			continue
+		}
+		p.files[file] = &preparedFile{done: make(chan struct{})}
+		queued = append(queued, file)
+	}
+	// hashes the index can reuse are not computed again
+	if index != nil {
+		for _, file := range queued {
+			e, ok := index.Files[file]
+			if fi, err := os.Stat(file); err == nil && ok && e.Size == fi.Size() && e.ModTime.Equal(fi.ModTime()) && e.Sha256 != "" {
+				p.reusable[file] = true
+			}
+		}
+	}
+	fmt.Printf("Preparing up to %d file(s) ahead\n", depth)
+	pipeline = p
+	go func() {
+		for _, file := range queued {
+			p.slots <- struct{}{}
+			go p.prepare(file, transforms)
+		}
+	}()
+}
+
+func (p *preparePipeline) prepare(file string, transforms []transformRule) {
+	r := p.files[file]
+	defer close(r.done)
+	r.suffix = transformFile(transforms, file)
+	content := transformedCopy(file)
+	if content == "" {
+		content = file
+	}
+	if !p.hashing || content == file && p.reusable[file] {
+		return
+	}
+	r.hash, r.err = computeHash(content)
+	p.mu.Lock()
+	r.hashed = content
+	p.byHashed[content] = r
+	p.mu.Unlock()
+}
+
+// transform returns the suffix the transform of a file added to its name,
+// waiting for the file to be prepared, and lets the pipeline prepare the
+// next file
+func (p *preparePipeline) transform(transforms []transformRule, file string) string {
+	if p == nil || p.files[file] == nil {
+		return transformFile(transforms, file)
+	}
+	p.release(file)
+	return p.files[file].suffix
+}
+
+// release waits for a file to be prepared and lets the pipeline prepare the
+// next file, also when the file ends up not being planned
+func (p *preparePipeline) release(file string) {
+	if p == nil || p.files[file] == nil {
+		return
+	}
+	<-p.files[file].done
+	p.mu.Lock()
+	if !p.released[file] {
+		p.released[file] = true
+		<-p.slots
+	}
+	p.mu.Unlock()
+}
+
+// hash returns the hash of a file computed by the pipeline, if any
+func (p *preparePipeline) hash(path string) (fileHash, error, bool) {
+	if p == nil {
+		return fileHash{}, nil, false
+	}
+	p.mu.Lock()
+	r := p.byHashed[path]
+	p.mu.Unlock()
+	if r == nil {
+		return fileHash{}, nil, false
+	}
+	return r.hash, r.err, true
+}
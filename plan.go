@@ -0,0 +1,599 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	dryRunInput    = "dryRun"
+	planFileInput  = "planFile"
+	applyPlanInput = "applyPlan"
+
+	overwriteCheckInput   = "overwriteCheck"
+	overwriteCheckVersion = "version"
+	overwriteCheckContent = "content"
+
+	actionCreateFolder = "createFolder"
+	actionCreate       = "create"
+	actionUpdate       = "update"
+)
+
+// operation is a single change to be made in Google Drive. Folders are
+// referenced by their path relative to the destination folder, because
+// folders created by the plan have no id until it is applied.
+type operation struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Folder string `json:"folder"`
+	Name   string `json:"name"`
+	// LogicalPath is the path the file is known by when it is stored under
+	// another path, e.g. by its hash
+	LogicalPath string `json:"logicalPath,omitempty"`
+	Source      string `json:"source,omitempty"`
+	// Upload is the transformed copy of the source uploaded instead of it
+	Upload string `json:"upload,omitempty"`
+	// Target is the source of the file a shortcut points to, or of the file
+	// a kept duplicate is stored as
+	Target      string `json:"target,omitempty"`
+	ParentId    string `json:"parentId,omitempty"`
+	FileId      string `json:"fileId,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	Description string `json:"description,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Sha256      string `json:"sha256,omitempty"`
+	Md5         string `json:"md5,omitempty"`
+	// Mode, Uid and Gid are the permissions and owner of the source file
+	Mode string `json:"mode,omitempty"`
+	Uid  string `json:"uid,omitempty"`
+	Gid  string `json:"gid,omitempty"`
+	// Revision labels the revision created by overwriting a file
+	Revision string `json:"revision,omitempty"`
+	// Scan is the result of the virus scan of the source file
+	Scan string `json:"scan,omitempty"`
+	// CreatedTime and ModifiedTime backdate the file to the dates given by
+	// the fileDates input
+	CreatedTime  string `json:"createdTime,omitempty"`
+	ModifiedTime string `json:"modifiedTime,omitempty"`
+	Reason       string `json:"reason"`
+
+	Precondition *precondition `json:"precondition,omitempty"`
+}
+
+// precondition is the remote state an operation was planned against
+type precondition struct {
+	// Absent is set when no file or folder with the name existed
+	Absent bool `json:"absent,omitempty"`
+	// Version is the version of the file to be updated
+	Version int64 `json:"version,omitempty"`
+	// Md5 is the checksum of the content of the file to be updated
+	Md5 string `json:"md5,omitempty"`
+}
+
+// overwriteCheck is how files are checked for changes by others between
+// looking them up and overwriting them, when not applying a plan file
+var overwriteCheck string
+
+type plan struct {
+	FolderId   string      `json:"folderId"`
+	Operations []operation `json:"operations"`
+	// Manifest is the name of the manifest uploaded after applying the plan
+	Manifest string `json:"manifest,omitempty"`
+	// Changelog is the name of the changelog updated after applying the plan
+	Changelog string `json:"changelog,omitempty"`
+	// Checksums is the name of the checksum database updated after applying
+	// the plan
+	Checksums string `json:"checksums,omitempty"`
+	// Replicas are the plans of the additional destinations, applied after
+	// this plan
+	Replicas []*plan `json:"replicas,omitempty"`
+	// Replica is set on the plans of additional destinations
+	Replica bool `json:"replica,omitempty"`
+	// Copy is set when files created in a replica are copied from the
+	// primary destination instead of uploaded
+	Copy bool `json:"copy,omitempty"`
+	// Routes are the ids of the folders outside of the destination files are
+	// routed to, by the path they are planned under
+	Routes map[string]string `json:"routes,omitempty"`
+	// Unplaced is why a replica is skipped, when it has no room for its files
+	Unplaced string `json:"-"`
+}
+
+// planner builds a plan by looking up the current state of the destination
+// without changing anything
+type planner struct {
+	svc     *drive.Service
+	plan    *plan
+	folders map[string]string
+	// fileIds are the ids of files not uploaded because they are already
+	// in Google Drive, by source
+	fileIds map[string]string
+	// stored are the sources planned to be stored by their content, by
+	// SHA-256
+	stored map[string]string
+	// checksums is the checksum database of the destination, if enabled
+	checksums *checksumDB
+}
+
+func newPlanner(svc *drive.Service, folderId string) *planner {
+	return &planner{
+		svc:     svc,
+		plan:    &plan{FolderId: folderId, Operations: []operation{}},
+		folders: map[string]string{"": folderId},
+		fileIds: map[string]string{},
+		stored:  map[string]string{},
+	}
+}
+
+func remotePath(folder string, name string) string {
+	if folder == "" {
+		return name
+	}
+	return folder + "/" + name
+}
+
+// resolveFolder returns the path and id of the folder made of dirs, planning
+// the creation of the folders that do not exist yet. The id is empty when
+// the folder is created by the plan.
+func (p *planner) resolveFolder(dirs []string) (string, string) {
+	folder := ""
+	id := p.plan.FolderId
+	for _, dir := range dirs {
+		parent := folder
+		folder = remotePath(folder, dir)
+		if known, ok := p.folders[folder]; ok {
+			id = known
+			continue
+		}
+		if id != "" {
+			id = p.findDirectory(id, dir)
+		}
+		if id == "" {
+			p.plan.Operations = append(p.plan.Operations, operation{
+				Action:       actionCreateFolder,
+				Path:         folder,
+				Folder:       parent,
+				Name:         dir,
+				Reason:       "folder does not exist",
+				Precondition: &precondition{Absent: true},
+			})
+		}
+		p.folders[folder] = id
+	}
+	return folder, id
+}
+
+func (p *planner) addFile(file string, dirs []string, name string, mimeType string, description string, overwriteFlag bool) {
+	fmt.Printf("target file name: %s\n", name)
+	folder := strings.Join(dirs, "/")
+	op := operation{
+		Action:      actionCreate,
+		Path:        remotePath(folder, name),
+		Folder:      folder,
+		Name:        name,
+		Source:      file,
+		MimeType:    mimeType,
+		Description: description,
+		Reason:      "overwrite is disabled",
+		Upload:      transformedCopy(file),
+		Scan:        scanResults[file],
+	}
+	op.recordMode()
+	op.recordDates()
+	if skipIfExists {
+		if _, parentId := p.resolveFolder(dirs); parentId != "" {
+			if existing := p.findFileInFolder(parentId, name); existing != nil {
+				p.skip(op, existing.Id, "a file with the same name exists")
+				return
+			}
+		}
+	}
+	if (state != nil || index != nil || compareContent || p.checksums != nil || metadataSidecars || conflictTemplate != nil) && file != stdinFilename {
+		h, err := hashContent(file)
+		if err != nil {
+			fatalf(fmt.Sprintf("hashing file %s failed with error: %v", file, err))
+		}
+		op.Sha256, op.Md5, op.Size = h.Sha256, h.Md5, h.Size
+		switch {
+		case p.plan.Replica:
+			// replicas are only compared with Google Drive
+		case state.isUploaded(op, p.plan.FolderId):
+			p.skip(op, state.Files[file].FileId, "already uploaded by a previous attempt")
+			return
+		case index.isUnchanged(op, p.plan.FolderId):
+			p.skip(op, index.Files[file].FileId, "unchanged since it was last uploaded")
+			return
+		case p.checksums.isUnchanged(op):
+			p.skip(op, p.checksums.Files[op.Path].FileId, "unchanged according to "+p.plan.Checksums)
+			return
+		}
+	}
+	_, op.ParentId = p.resolveFolder(dirs)
+	if overwriteFlag || conflictTemplate != nil {
+		existing := p.findFile(op.ParentId, name)
+		if existing == nil {
+			existing = registry.lookup(p.svc, p.plan.FolderId, op.Path, op.ParentId)
+		}
+		if existing != nil && conflictTemplate != nil {
+			switch resolveConflict(op, existing) {
+			case conflictSkip:
+				p.skip(op, existing.Id, "skipped by "+conflictExpressionInput)
+				return
+			case conflictFail:
+				fatalf(fmt.Sprintf("%s conflicts with %s (%s) according to %s", file, op.Path, existing.Id, conflictExpressionInput))
+			case conflictCreate:
+				fmt.Printf("Creating %s next to the existing file (%s)\n", op.Path, existing.Id)
+				op.Reason = "created by " + conflictExpressionInput
+			case conflictOverwrite:
+				fmt.Printf("Overwriting file: %s (%s)\n", existing.Name, existing.Id)
+				op.Action = actionUpdate
+				op.FileId = existing.Id
+				op.Reason = "overwritten by " + conflictExpressionInput
+				op.Precondition = &precondition{Version: existing.Version, Md5: existing.Md5Checksum}
+				op.markRevision()
+			}
+			p.plan.Operations = append(p.plan.Operations, op)
+			return
+		}
+		switch {
+		case existing != nil && compareContent && op.Md5 != "" && existing.Md5Checksum == op.Md5 && metadataChanged(op, existing):
+			fmt.Printf("Content of %s is unchanged in Google Drive (%s), updating its metadata only\n", file, existing.Id)
+			op.Action = actionUpdateMetadata
+			op.FileId = existing.Id
+			op.Reason = "content is unchanged, metadata changed"
+			op.Precondition = &precondition{Version: existing.Version, Md5: existing.Md5Checksum}
+		case existing != nil && (index != nil || compareContent) && op.Md5 != "" && existing.Md5Checksum == op.Md5:
+			if !p.plan.Replica {
+				index.record(op, p.plan.FolderId, existing.Id)
+			}
+			p.skip(op, existing.Id, "content is unchanged in Google Drive")
+			return
+		case existing != nil && isAppendOnly(file) && appendOnlySkip(op, existing) != "":
+			p.skip(op, existing.Id, appendOnlySkip(op, existing))
+			return
+		case existing != nil:
+			fmt.Printf("Overwriting file: %s (%s)\n", existing.Name, existing.Id)
+			op.Action = actionUpdate
+			op.FileId = existing.Id
+			op.Reason = "file with the same name exists"
+			op.Precondition = &precondition{Version: existing.Version, Md5: existing.Md5Checksum}
+			op.markRevision()
+		default:
+			fmt.Println("No similar files found. Creating a new file")
+			op.Reason = "no file with the same name exists"
+			op.Precondition = &precondition{Absent: true}
+		}
+	}
+	p.plan.Operations = append(p.plan.Operations, op)
+}
+
+func printPlan(pl *plan) {
+	kind := "Plan"
+	if pl.Replica {
+		kind = "Replica plan"
+	}
+	fmt.Printf("%s: %d operation(s) in folder %s\n", kind, len(pl.Operations), pl.FolderId)
+	for _, op := range pl.Operations {
+		fmt.Printf("  %-12s %s (%s)\n", op.Action, op.Path, op.Reason)
+	}
+	for _, r := range pl.Replicas {
+		printPlan(r)
+	}
+}
+
+// outputPlan exposes the plan as the 'plan' output and writes it to planFile
+// if set
+func outputPlan(pl *plan, planFile string) {
+	data, err := json.Marshal(pl)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding plan failed with error: %v", err))
+	}
+	githubactions.SetOutput("plan", string(data))
+	if planFile != "" {
+		if err := os.WriteFile(planFile, data, 0644); err != nil {
+			fatalf(fmt.Sprintf("writing plan to %s failed with error: %v", planFile, err))
+		}
+		fmt.Printf("Plan written to %s\n", planFile)
+	}
+}
+
+func loadPlan(path string) *plan {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fatalf(fmt.Sprintf("reading plan failed with error: %v", err))
+	}
+	var pl plan
+	if err := json.Unmarshal(data, &pl); err != nil {
+		fatalf(fmt.Sprintf("parsing plan %s failed with error: %v", path, err))
+	}
+	if pl.FolderId == "" {
+		fatalf(fmt.Sprintf("plan %s has no folderId", path))
+	}
+	fmt.Printf("Applying plan from %s\n", path)
+	printPlan(&pl)
+	return &pl
+}
+
+// checkPrecondition verifies that the remote state an operation was planned
+// against did not change. Folders created by the plan itself have nothing to
+// check against.
+func checkPrecondition(svc *drive.Service, op operation, parentId string, parentCreated bool) error {
+	if op.Precondition == nil {
+		return nil
+	}
+	switch {
+	case op.Precondition.Absent && !parentCreated:
+		if op.Action == actionCreateFolder {
+			if id := findDriveDirectory(svc, parentId, op.Name); id != "" {
+				return fmt.Errorf("folder %s was created since planning (%s)", op.Path, id)
+			}
+		} else if f := findDriveFileInFolder(svc, parentId, op.Name); f != nil {
+			return fmt.Errorf("file %s was created since planning (%s)", op.Path, f.Id)
+		}
+	case op.Precondition.Version != 0:
+		f, err := svc.Files.Get(op.FileId).Fields("id,version,trashed").SupportsAllDrives(allDrives).Do()
+		if err != nil {
+			return fmt.Errorf("looking up file %s (%s) failed with error: %v", op.Path, op.FileId, err)
+		}
+		if f.Trashed {
+			return fmt.Errorf("file %s (%s) was trashed since planning", op.Path, op.FileId)
+		}
+		if f.Version != op.Precondition.Version {
+			return fmt.Errorf("file %s (%s) changed since planning: version %d, planned against version %d", op.Path, op.FileId, f.Version, op.Precondition.Version)
+		}
+	}
+	return nil
+}
+
+// checkOverwrite verifies that a file was not changed by someone else since
+// it was looked up, so that their update is not lost by overwriting it.
+// Depending on overwriteCheck any new version, or only new content, is a
+// conflict.
+func checkOverwrite(svc *drive.Service, op operation) error {
+	if overwriteCheck == "" || op.Precondition == nil || op.Precondition.Version == 0 {
+		return nil
+	}
+	f, err := svc.Files.Get(op.FileId).Fields("id,version,md5Checksum,trashed").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return fmt.Errorf("looking up file %s (%s) failed with error: %w", op.Path, op.FileId, err)
+	}
+	switch {
+	case f.Trashed:
+		return fmt.Errorf("file %s (%s) was trashed since it was looked up, not overwriting it", op.Path, op.FileId)
+	case overwriteCheck == overwriteCheckVersion && f.Version != op.Precondition.Version:
+		return fmt.Errorf("file %s (%s) changed since it was looked up: version %d, looked up version %d, not overwriting it", op.Path, op.FileId, f.Version, op.Precondition.Version)
+	case overwriteCheck == overwriteCheckContent && f.Md5Checksum != op.Precondition.Md5:
+		return fmt.Errorf("content of file %s (%s) changed since it was looked up, not overwriting it", op.Path, op.FileId)
+	}
+	return nil
+}
+
+// applyPlan executes the operations of a plan, then of its replicas. When
+// verify is set the precondition of every operation is checked right before
+// it is applied. Failed uploads are recorded and do not stop the remaining
+// operations.
+func applyPlan(svc *drive.Service, pl *plan, verify bool) {
+	checkFreeSpace(svc, pl)
+	journal.begin(pl)
+	ids := applyOperations(svc, pl, verify, nil)
+	applyReplicas(svc, pl, ids, verify)
+}
+
+// applyOperations executes the operations of a plan and returns the ids of
+// the files uploaded or kept, by source. Files created by the plan are
+// copied from the files in copyFrom when set.
+func applyOperations(svc *drive.Service, pl *plan, verify bool, copyFrom map[string]string) map[string]string {
+	folders := map[string]string{"": pl.FolderId}
+	for dir, id := range pl.Routes {
+		folders[dir] = id
+	}
+	created := map[string]bool{}
+	ids := map[string]string{}
+	processed := 0
+	batch := func() {
+		processed++
+		if batchSize > 0 && processed%batchSize == 0 {
+			checkpoint(svc, pl, processed)
+		}
+	}
+	done := func(op operation, id string) {
+		journal.complete(pl.FolderId, op, id)
+		ids[op.Source] = id
+		registry.record(pl.FolderId, op.Path, id)
+		// replicas are not tracked, so that they never hide the state of the
+		// primary destination
+		if pl.Replica {
+			return
+		}
+		state.record(op, pl.FolderId, id)
+		index.record(op, pl.FolderId, id)
+		runManifest.add(op, id)
+		batch()
+	}
+	fail := func(op operation, err error) {
+		if pl.Replica {
+			err = fmt.Errorf("replica %s: %w", pl.FolderId, err)
+		}
+		recordFailure(op, err)
+		if !pl.Replica {
+			batch()
+		}
+	}
+	// uploads that exceed fileTimeout are retried once after the others
+	queue := append([]operation{}, pl.Operations...)
+	retried := map[int]bool{}
+	// retries are the number of times each file was sent again, after
+	// exceeding fileTimeout, a transient error or being rate limited
+	retries := map[string]int{}
+	// createStarted is when files were first attempted to be created, to
+	// find the files created by attempts that failed before retrying
+	createStarted := map[string]time.Time{}
+	for _, op := range pl.Operations {
+		if t, ok := journal.startedAt(pl.FolderId, op); ok {
+			createStarted[op.Path] = t
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		op := queue[i]
+		// skip the operations completed by the resumed session
+		if id, ok := journal.done(pl.FolderId, op); ok {
+			switch op.Action {
+			case actionCreateFolder:
+				folders[op.Path] = id
+				created[op.Path] = true
+			case actionShortcut:
+			default:
+				done(op, id)
+			}
+			continue
+		}
+		if verify {
+			parentId := op.ParentId
+			if parentId == "" {
+				parentId = folders[op.Folder]
+			}
+			if err := checkPrecondition(svc, op, parentId, created[op.Folder]); err != nil {
+				fatalf(fmt.Sprintf("precondition of %s %s failed: %v. the destination changed since the plan was made, please plan again", op.Action, op.Path, err))
+			}
+		}
+		if !verify && (op.Action == actionUpdate || op.Action == actionUpdateMetadata) {
+			if err := checkOverwrite(svc, op); err != nil {
+				fail(op, err)
+				continue
+			}
+		}
+		if op.Action != actionCreateFolder {
+			logGroups.enter("Uploading", op.Source, op.Folder)
+		}
+		switch op.Action {
+		case actionCreateFolder:
+			logGroups.end()
+			id, _ := createDriveDirectory(svc, folders[op.Folder], op.Name)
+			folders[op.Path] = id
+			created[op.Path] = true
+			journal.complete(pl.FolderId, op, id)
+		case actionCreate, actionUpdate:
+			if op.ParentId == "" {
+				op.ParentId = folders[op.Folder]
+			}
+			parentId := op.ParentId
+			if skipMissing(op) {
+				continue
+			}
+			var existing *drive.File
+			if op.Action == actionUpdate {
+				existing = &drive.File{Id: op.FileId}
+			}
+			start := time.Now()
+			rateLimited := apiPacer.retryCount()
+			var uploaded *drive.File
+			var err error
+			if since, ok := createStarted[op.Path]; ok && existing == nil {
+				uploaded = findCreatedFile(svc, op, parentId, since)
+			}
+			if uploaded != nil {
+				fmt.Printf("%s was created by the attempt that failed (%s), not uploading it again\n", op.Path, uploaded.Id)
+			} else if sourceId := copyFrom[op.Source]; sourceId != "" && existing == nil {
+				uploaded, err = copyToDrive(svc, sourceId, parentId, op)
+			} else if pl.Replica && op.Source == stdinFilename {
+				err = fmt.Errorf("stdin was already consumed, replicas of stdin can only be copied")
+			} else {
+				if _, ok := createStarted[op.Path]; !ok && existing == nil {
+					createStarted[op.Path] = start
+					journal.start(pl.FolderId, op, start)
+				}
+				ctx, cancel := fileContext()
+				uploaded, err = uploadToDriveContext(ctx, svc, op.content(), parentId, existing, op.Name, op.MimeType, op.Description, op.appProperties(), op.CreatedTime, op.ModifiedTime)
+				cancel()
+				if ctx.Err() == context.DeadlineExceeded && op.Source != stdinFilename {
+					if !retried[i] {
+						fmt.Printf("Uploading %s exceeded %v, retrying it after the other files\n", op.Path, fileTimeout)
+						retries[op.Path] += apiPacer.retryCount() - rateLimited + 1
+						retried[len(queue)] = true
+						queue = append(queue, op)
+					} else {
+						githubactions.Warningf(fmt.Sprintf("uploading %s exceeded %v twice, skipping it", op.Path, fileTimeout))
+						slowFiles = append(slowFiles, op.Source)
+					}
+					continue
+				}
+				if err != nil && existing == nil && isTransientError(err) && !retried[i] && op.Source != stdinFilename {
+					fmt.Printf("Creating %s failed with error: %v, retrying it after the other files\n", op.Path, err)
+					retries[op.Path] += apiPacer.retryCount() - rateLimited + 1
+					retried[len(queue)] = true
+					queue = append(queue, op)
+					continue
+				}
+			}
+			var size int64
+			if uploaded != nil {
+				size = uploaded.Size
+			}
+			stats.record(size, time.Since(start), err)
+			retries[op.Path] += apiPacer.retryCount() - rateLimited
+			otel.recordUpload(op, size, start, retries[op.Path], err)
+			if err != nil {
+				fail(op, err)
+			} else if uploaded != nil {
+				fmt.Printf("Uploaded %s (%s) in %s\n", op.Path, formatBytes(size), formatDuration(time.Since(start)))
+				done(op, uploaded.Id)
+				if metadataSidecars {
+					if err := uploadSidecar(svc, op, parentId, uploaded.Id); err != nil {
+						fail(op, err)
+					}
+				}
+			}
+		case actionUpdateMetadata:
+			updated, err := updateMetadata(svc, op)
+			if err != nil {
+				fail(op, err)
+			} else {
+				done(op, updated.Id)
+				if metadataSidecars {
+					if err := uploadSidecar(svc, op, op.ParentId, updated.Id); err != nil {
+						fail(op, err)
+					}
+				}
+			}
+		case actionKeep:
+			if op.FileId == "" {
+				op.FileId = ids[op.Target]
+			}
+			if op.FileId == "" {
+				fail(op, fmt.Errorf("%s was not uploaded", op.Target))
+				continue
+			}
+			done(op, op.FileId)
+		case actionSkip:
+		case actionShortcut:
+			parentId := op.ParentId
+			if parentId == "" {
+				parentId = folders[op.Folder]
+			}
+			targetId := ids[op.Target]
+			if targetId == "" {
+				targetId = op.FileId
+			}
+			if targetId == "" {
+				fail(op, fmt.Errorf("%s was not uploaded", op.Target))
+				continue
+			}
+			if s, err := createShortcut(svc, parentId, op.Name, targetId, nil); err != nil {
+				fail(op, err)
+			} else {
+				journal.complete(pl.FolderId, op, s.Id)
+			}
+		default:
+			fatalf(fmt.Sprintf("unknown plan action '%s'", op.Action))
+		}
+	}
+	logGroups.end()
+	return ids
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const uploadPolicyName = ".upload-policy.json"
+
+// uploadPolicy lets the owners of a destination folder control how CI
+// publishes into it: defaults are used for inputs the workflow does not set,
+// enforced values override the inputs of the workflow
+type uploadPolicy struct {
+	Defaults map[string]json.RawMessage `json:"defaults"`
+	Enforced map[string]json.RawMessage `json:"enforced"`
+}
+
+var policyDefaults, policyEnforced = map[string]string{}, map[string]string{}
+
+// policyInputs are the only inputs a policy can set: how the files published
+// into the folder are named, how existing files are overwritten and what is
+// recorded about the uploads. Anyone able to edit the folder can write a
+// policy, so inputs running commands, sharing files or touching the runner
+// or other destinations are never accepted.
+var policyInputs = map[string]bool{
+	// naming
+	nameInput:                  true,
+	namePrefixInput:            true,
+	namePatternInput:           true,
+	descriptionInput:           true,
+	descriptionFromCommitInput: true,
+	appendSourceExtInput:       true,
+	useCompleteSourceName:      true,
+	mirrorDirectoryStructure:   true,
+	groupByTopDirInput:         true,
+	pathRewriteInput:           true,
+	layoutInput:                true,
+	longPathsInput:             true,
+	// overwriting
+	overwriteInput:           true,
+	overwriteCheckInput:      true,
+	conflictExpressionInput:  true,
+	skipIfExistsInput:        true,
+	compareContentInput:      true,
+	appendOnlyFilesInput:     true,
+	minSizeIncreaseInput:     true,
+	revisionMarkerInput:      true,
+	revisionMarkerFieldInput: true,
+	// bookkeeping
+	manifestNameInput:     true,
+	changelogInput:        true,
+	checksumsNameInput:    true,
+	statusNameInput:       true,
+	recordProvenanceInput: true,
+}
+
+func policyValues(values map[string]json.RawMessage) map[string]string {
+	parsed := map[string]string{}
+	for k, raw := range values {
+		if !policyInputs[k] {
+			invalidInput(fmt.Sprintf("input '%s' cannot be set in %s, only naming, overwriting and bookkeeping inputs can", k, uploadPolicyName))
+		}
+		// accept plain JSON values (booleans, numbers) as well as strings
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		parsed[k] = strings.TrimSpace(s)
+	}
+	return parsed
+}
+
+// loadUploadPolicy reads the upload policy of the destination folder, if it
+// has one
+func loadUploadPolicy() {
+	if getInput(folderIdInput) == "" && getInput(folderPropertyInput) == "" {
+		return
+	}
+	svc := newDriveService()
+	folderId := destinationFolderId(svc)
+	f := findDriveFileInFolder(svc, folderId, uploadPolicyName)
+	if f == nil {
+		return
+	}
+	data, err := downloadDriveFile(svc, f)
+	if err != nil {
+		fatalf(fmt.Sprintf("downloading %s of folder %s failed with error: %v", uploadPolicyName, folderId, err))
+	}
+	var p uploadPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		fatalf(fmt.Sprintf("parsing %s of folder %s failed with error: %v", uploadPolicyName, folderId, err))
+	}
+	policyDefaults = policyValues(p.Defaults)
+	policyEnforced = policyValues(p.Enforced)
+	for k, v := range policyEnforced {
+		if set := getInputUnenforced(k); set != "" && set != v {
+			githubactions.Warningf(fmt.Sprintf("input '%s' is enforced to '%s' by %s of folder %s", k, v, uploadPolicyName, folderId))
+		}
+	}
+	fmt.Printf("Using %s of folder %s: %d default(s), %d enforced input(s)\n", uploadPolicyName, folderId, len(policyDefaults), len(policyEnforced))
+}
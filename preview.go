@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	previewModeInput    = "previewMode"
+	githubTokenInput    = "githubToken"
+	previewFolderPrefix = "pr-"
+	// previewCommentMarker identifies the comment of the action on a pull
+	// request, so that it is updated instead of commenting again
+	previewCommentMarker = "<!-- gdrive-upload-preview -->"
+)
+
+// pullRequestEvent is the part of a pull_request event payload the preview
+// mode needs
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+}
+
+// readPullRequestEvent returns the pull request event that triggered the
+// workflow, or nil for other events
+func readPullRequestEvent() *pullRequestEvent {
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var event pullRequestEvent
+	if json.Unmarshal(data, &event) != nil || event.PullRequest == nil {
+		return nil
+	}
+	if event.Number == 0 {
+		event.Number = event.PullRequest.Number
+	}
+	return &event
+}
+
+// previewEvent returns the pull request event of a preview, failing for
+// other events
+func previewEvent() *pullRequestEvent {
+	event := readPullRequestEvent()
+	if event == nil {
+		invalidInput(fmt.Sprintf("input '%v' needs a pull_request or pull_request_target event", previewModeInput))
+	}
+	return event
+}
+
+func previewFolderName(event *pullRequestEvent) string {
+	return fmt.Sprintf("%s%d", previewFolderPrefix, event.Number)
+}
+
+func folderLink(id string) string {
+	return "https://drive.google.com/drive/folders/" + id
+}
+
+// previewFolder returns the id of the folder of the pull request in the
+// destination folder, creating it if needed. Uploaded files record their
+// provenance, so that they can also be cleaned up by branch. A dry run does
+// not create the folder, and returns an empty id when it does not exist yet.
+func previewFolder(svc *drive.Service, folderId string, dryRun bool) string {
+	event := previewEvent()
+	name := previewFolderName(event)
+	var id string
+	if dryRun {
+		if id = findDriveDirectory(svc, folderId, name); id == "" {
+			fmt.Printf("Would create folder %s for the preview of pull request #%d\n", name, event.Number)
+			return ""
+		}
+	} else {
+		id, _ = createDriveDirectory(svc, folderId, name)
+	}
+	fmt.Printf("Uploading the preview of pull request #%d to folder %s (%s)\n", event.Number, name, id)
+	if provenance == nil {
+		provenance = map[string]string{}
+		for k, v := range currentProvenance() {
+			if v != "" {
+				provenance[k] = v
+			}
+		}
+	}
+	githubactions.SetOutput("previewUrl", folderLink(id))
+	return id
+}
+
+// closedPreview reports whether the event is the closing of a pull request
+// in preview mode
+func closedPreview() bool {
+	if !getBoolInput(previewModeInput) {
+		return false
+	}
+	return previewEvent().Action == "closed"
+}
+
+// removePreview moves the folder of a closed pull request to the trash
+func removePreview(svc *drive.Service, folderId string) {
+	event := previewEvent()
+	name := previewFolderName(event)
+	id := findDriveDirectory(svc, folderId, name)
+	if id == "" {
+		fmt.Printf("Pull request #%d has no preview folder %s\n", event.Number, name)
+		return
+	}
+	if _, err := svc.Files.Update(id, &drive.File{Trashed: true}).Fields("id").SupportsAllDrives(allDrives).Do(); err != nil {
+		fatalf(fmt.Sprintf("moving preview folder %s (%s) to the trash failed with error: %v", name, id, err))
+	}
+	fmt.Printf("Moved the preview folder %s (%s) of closed pull request #%d to the trash\n", name, id, event.Number)
+	commentPreview(fmt.Sprintf("The preview uploaded to Google Drive was removed, as the pull request is %s.", event.Action))
+}
+
+// commentPreview adds a comment to the pull request, or updates the one
+// added by an earlier run, when a GitHub token is given
+func commentPreview(body string) {
+	token := getInput(githubTokenInput)
+	if token == "" {
+		fmt.Printf("No '%v' given, not commenting on the pull request\n", githubTokenInput)
+		return
+	}
+	githubactions.AddMask(token)
+	event := previewEvent()
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	repo := os.Getenv("GITHUB_REPOSITORY")
+	body = previewCommentMarker + "\n" + body
+	client := &http.Client{Timeout: 30 * time.Second}
+	// call returns the link of the next page of a listing, if any
+	call := func(method string, url string, payload interface{}, result interface{}) (string, error) {
+		var reader io.Reader
+		if payload != nil {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return "", err
+			}
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			data, _ := io.ReadAll(resp.Body)
+			return "", fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(data)))
+		}
+		if result != nil {
+			err = json.NewDecoder(resp.Body).Decode(result)
+		}
+		return nextPageLink(resp.Header.Get("Link")), err
+	}
+
+	// the comment of earlier runs may be on any page of a busy pull request
+	var existing int64
+	var err error
+	for url := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=100", api, repo, event.Number); url != "" && existing == 0; {
+		var comments []struct {
+			Id   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		if url, err = call("GET", url, nil, &comments); err != nil {
+			break
+		}
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, previewCommentMarker) {
+				existing = c.Id
+				break
+			}
+		}
+	}
+	if err == nil {
+		payload := map[string]string{"body": body}
+		if existing != 0 {
+			_, err = call("PATCH", fmt.Sprintf("%s/repos/%s/issues/comments/%d", api, repo, existing), payload, nil)
+		} else {
+			_, err = call("POST", fmt.Sprintf("%s/repos/%s/issues/%d/comments", api, repo, event.Number), payload, nil)
+		}
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("commenting on pull request #%d failed with error: %v", event.Number, err))
+	}
+}
+
+// nextPageLink returns the link of the next page given by the Link header of
+// a GitHub API response, or an empty string on the last page
+func nextPageLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// commentPreviewLink comments the link of the preview folder on the pull
+// request once the files are uploaded
+func commentPreviewLink(folderId string) {
+	body := fmt.Sprintf("The preview of this pull request is uploaded to [Google Drive](%s)", folderLink(folderId))
+	if sha := os.Getenv("GITHUB_SHA"); sha != "" {
+		body += fmt.Sprintf(" for %s", sha)
+	}
+	body += fmt.Sprintf(": %d file(s) uploaded", stats.files["success"])
+	if n := len(failedFiles); n > 0 {
+		body += fmt.Sprintf(", %d failed", n)
+	}
+	commentPreview(body + ".")
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestNextPageLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"no header", "", ""},
+		{"first page", `<https://api.github.com/repositories/1/issues/2/comments?per_page=100&page=2>; rel="next", <https://api.github.com/repositories/1/issues/2/comments?per_page=100&page=5>; rel="last"`, "https://api.github.com/repositories/1/issues/2/comments?per_page=100&page=2"},
+		{"middle page", `<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next", <https://api.github.com/x?page=1>; rel="first"`, "https://api.github.com/x?page=3"},
+		{"last page", `<https://api.github.com/x?page=4>; rel="prev", <https://api.github.com/x?page=1>; rel="first"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPageLink(tt.header); got != tt.want {
+				t.Errorf("nextPageLink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	recordProvenanceInput = "recordProvenance"
+	cleanupInput          = "cleanup"
+
+	repositoryProperty = "gdriveUploadRepository"
+	workflowProperty   = "gdriveUploadWorkflow"
+	branchProperty     = "gdriveUploadBranch"
+
+	// maxPropertyBytes is the longest key and value of an app property
+	maxPropertyBytes = 124
+)
+
+// provenanceFilters are the names of the cleanup filters and the app
+// properties they match
+var provenanceFilters = map[string]string{
+	"repository": repositoryProperty,
+	"workflow":   workflowProperty,
+	"branch":     branchProperty,
+}
+
+// provenance are the app properties recording where uploaded files come
+// from, if recordProvenance is enabled
+var provenance map[string]string
+
+func parseProvenance() {
+	if !getBoolInput(recordProvenanceInput) {
+		return
+	}
+	provenance = map[string]string{}
+	for k, v := range currentProvenance() {
+		if v != "" {
+			provenance[k] = v
+		}
+	}
+}
+
+// currentProvenance returns the provenance of the files uploaded by this run
+func currentProvenance() map[string]string {
+	return map[string]string{
+		repositoryProperty: propertyValue(repositoryProperty, os.Getenv("GITHUB_REPOSITORY")),
+		workflowProperty:   propertyValue(workflowProperty, os.Getenv("GITHUB_WORKFLOW")),
+		branchProperty:     propertyValue(branchProperty, newNameData(stdinFilename).Branch),
+	}
+}
+
+// propertyValue truncates a value to fit in an app property with its key,
+// without splitting a multi-byte character
+func propertyValue(key string, value string) string {
+	if max := maxPropertyBytes - len(key); len(value) > max {
+		for max > 0 && !utf8.RuneStart(value[max]) {
+			max--
+		}
+		value = value[:max]
+	}
+	return value
+}
+
+// parseCleanupFilters parses the cleanup filters, one 'name=value' per line.
+// The repository is the current one unless given.
+func parseCleanupFilters(value string) map[string]string {
+	filters := map[string]string{repositoryProperty: currentProvenance()[repositoryProperty]}
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		property, ok := provenanceFilters[strings.TrimSpace(kv[0])]
+		if len(kv) != 2 || !ok {
+			invalidInput(fmt.Sprintf("invalid filter '%v' in input '%v', must be of the form repository=, workflow= or branch=value", line, cleanupInput))
+		}
+		filters[property] = propertyValue(property, strings.TrimSpace(kv[1]))
+	}
+	if filters[repositoryProperty] == "" {
+		invalidInput(fmt.Sprintf("input '%v' needs a repository filter outside of GitHub Actions", cleanupInput))
+	}
+	return filters
+}
+
+// cleanupByProvenance moves the files of the destination subtree uploaded
+// with recordProvenance and matching all the filters to the trash, or only
+// lists them in a dry run, and exposes their number as the 'cleanedFiles'
+// output. Folders are kept.
+func cleanupByProvenance(svc *drive.Service, folderId string, filters map[string]string, dryRun bool) {
+	data, _ := json.Marshal(filters)
+	fmt.Printf("Looking for files in folder %s uploaded with %s\n", folderId, data)
+	type match struct{ id, path string }
+	var matches []match
+	err := walkTree(svc, folderId, "id,name,mimeType,appProperties", func(folderPath string, f *drive.File) {
+		if f.MimeType == folderMimeType {
+			return
+		}
+		for k, v := range filters {
+			if f.AppProperties[k] != v {
+				return
+			}
+		}
+		matches = append(matches, match{f.Id, remotePath(folderPath, f.Name)})
+	})
+	if err != nil {
+		fatalf(err.Error())
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+	cleaned := 0
+	for _, m := range matches {
+		if dryRun {
+			fmt.Printf("Would move %s (%s) to the trash\n", m.path, m.id)
+			continue
+		}
+		if _, err := svc.Files.Update(m.id, &drive.File{Trashed: true}).Fields("id").SupportsAllDrives(allDrives).Do(); err != nil {
+			recordFailure(operation{Source: m.path, Path: m.path}, fmt.Errorf("moving %s to the trash failed with error: %w", m.id, err))
+			continue
+		}
+		fmt.Printf("Moved %s (%s) to the trash\n", m.path, m.id)
+		cleaned++
+	}
+	fmt.Printf("%d file(s) matched, %d moved to the trash\n", len(matches), cleaned)
+	githubactions.SetOutput("cleanedFiles", fmt.Sprint(cleaned))
+}
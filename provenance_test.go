@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPropertyValue(t *testing.T) {
+	key := "gdriveUploadBranch"
+	max := maxPropertyBytes - len(key)
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"short", "main", "main"},
+		{"exact fit", strings.Repeat("a", max), strings.Repeat("a", max)},
+		{"ascii", strings.Repeat("a", max+10), strings.Repeat("a", max)},
+		{"rune across the limit", strings.Repeat("a", max-1) + "é", strings.Repeat("a", max-1)},
+		{"rune at the limit", strings.Repeat("a", max-2) + "é" + "b", strings.Repeat("a", max-2) + "é"},
+		{"four byte rune", strings.Repeat("a", max-2) + "🚀", strings.Repeat("a", max-2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := propertyValue(key, tt.value)
+			if got != tt.want {
+				t.Errorf("propertyValue() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("propertyValue() = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
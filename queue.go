@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	resumeInput      = "resume"
+	queueFileInput   = "queueFile"
+	defaultQueueFile = ".gdrive-upload/queue.jsonl"
+)
+
+// queueEvent is a line of the queue file after the plan: an operation of a
+// destination that was started or completed
+type queueEvent struct {
+	FolderId string    `json:"folderId"`
+	Action   string    `json:"action"`
+	Path     string    `json:"path"`
+	Id       string    `json:"id,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+}
+
+func (e queueEvent) key() string {
+	return e.FolderId + " " + e.Action + " " + e.Path
+}
+
+// workQueue persists the plan being applied and the completion of each of
+// its operations to a local file, so that an interrupted session can be
+// resumed where it stopped. The first line of the file is the plan, and each
+// following line a queueEvent.
+type workQueue struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	completed map[string]string
+	started   map[string]time.Time
+	// resumed is set when the plan was read from the file
+	resumed bool
+}
+
+// journal is nil unless resume is enabled
+var journal *workQueue
+
+// openQueue enables resume, reading the queue file left by an interrupted
+// session. It returns the plan to resume, or nil when there is none. Dry
+// runs plan again without touching the queue file.
+func openQueue() *plan {
+	if !getBoolInput(resumeInput) || getBoolInput(dryRunInput) {
+		return nil
+	}
+	if getInput(transformInput) != "" || getInput(gzipPatternsInput) != "" {
+		invalidInput(fmt.Sprintf("inputs '%v' and '%v' cannot be used with '%v', transformed files only exist during the run", transformInput, gzipPatternsInput, resumeInput))
+	}
+	path := getInput(queueFileInput)
+	if path == "" {
+		path = defaultQueueFile
+	}
+	journal = &workQueue{path: path, completed: map[string]string{}, started: map[string]time.Time{}}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading queue file %s failed with error: %v", path, err))
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<30)
+	var pl plan
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &pl) != nil || pl.FolderId == "" {
+		fatalf(fmt.Sprintf("queue file %s has no plan, delete it to start a new session", path))
+	}
+	for scanner.Scan() {
+		var e queueEvent
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			// the last line may be truncated by the interruption
+			continue
+		}
+		if e.Id != "" {
+			journal.completed[e.key()] = e.Id
+		} else if !e.Started.IsZero() {
+			journal.started[e.key()] = e.Started
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf(fmt.Sprintf("reading queue file %s failed with error: %v", path, err))
+	}
+	journal.resumed = true
+	fmt.Printf("Resuming the session of %s: %d operation(s) already done\n", path, len(journal.completed))
+	printPlan(&pl)
+	return &pl
+}
+
+// begin writes the plan about to be applied, unless it is being resumed
+func (q *workQueue) begin(pl *plan) {
+	if q == nil {
+		return
+	}
+	flags := os.O_APPEND | os.O_WRONLY
+	if !q.resumed {
+		flags = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	}
+	err := os.MkdirAll(filepath.Dir(q.path), 0755)
+	if err == nil {
+		q.file, err = os.OpenFile(q.path, flags, 0644)
+	}
+	if err == nil && !q.resumed {
+		var data []byte
+		if data, err = json.Marshal(pl); err == nil {
+			_, err = q.file.Write(append(data, '\n'))
+		}
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("writing queue file %s failed with error: %v", q.path, err))
+	}
+}
+
+func (q *workQueue) write(e queueEvent) {
+	data, _ := json.Marshal(e)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing queue file %s failed with error: %v", q.path, err))
+	}
+}
+
+// done returns the id of the file or folder of an operation completed by an
+// earlier session
+func (q *workQueue) done(folderId string, op operation) (string, bool) {
+	if q == nil {
+		return "", false
+	}
+	id, ok := q.completed[queueEvent{FolderId: folderId, Action: op.Action, Path: op.Path}.key()]
+	return id, ok
+}
+
+// startedAt returns when an earlier session started creating the file of an
+// operation it did not complete
+func (q *workQueue) startedAt(folderId string, op operation) (time.Time, bool) {
+	if q == nil {
+		return time.Time{}, false
+	}
+	t, ok := q.started[queueEvent{FolderId: folderId, Action: op.Action, Path: op.Path}.key()]
+	return t, ok
+}
+
+func (q *workQueue) start(folderId string, op operation, started time.Time) {
+	if q == nil || q.file == nil {
+		return
+	}
+	q.write(queueEvent{FolderId: folderId, Action: op.Action, Path: op.Path, Started: started})
+}
+
+func (q *workQueue) complete(folderId string, op operation, id string) {
+	if q == nil || q.file == nil {
+		return
+	}
+	if _, ok := q.done(folderId, op); ok {
+		return
+	}
+	q.write(queueEvent{FolderId: folderId, Action: op.Action, Path: op.Path, Id: id})
+}
+
+// finish removes the queue file once every operation succeeded, so that the
+// next session starts with a new plan
+func (q *workQueue) finish() {
+	if q == nil || q.file == nil {
+		return
+	}
+	q.file.Close()
+	if len(failedFiles) > 0 {
+		fmt.Printf("Keeping queue file %s to resume the failed operations\n", q.path)
+		return
+	}
+	if err := os.Remove(q.path); err != nil {
+		githubactions.Warningf(fmt.Sprintf("removing queue file %s failed with error: %v", q.path, err))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	maxTotalSizeInput       = "maxTotalSize"
+	maxTotalSizePolicyInput = "maxTotalSizePolicy"
+	maxTotalSizeFail        = "fail"
+	maxTotalSizeTrim        = "trim"
+)
+
+// sizeUnits are the suffixes accepted by parseSize, longest first
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"TB", 1000 * 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseSize parses a number of bytes with an optional unit, e.g. 500MB or
+// 2GiB
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	// NaN fails every comparison, so only sizes in range are accepted
+	if err != nil || !(n >= 0 && n*float64(unit) < math.MaxInt64) {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// limitTotalSize fails when the matched files are larger than maxTotalSize
+// in total, or with the trim policy skips the files that do not fit anymore
+func limitTotalSize(files []string) []string {
+	v := getInput(maxTotalSizeInput)
+	if v == "" {
+		return files
+	}
+	limit, err := parseSize(v)
+	if err != nil {
+		invalidInput(fmt.Sprintf("input '%v' must be a size, e.g. 500MB, got '%v'", maxTotalSizeInput, v))
+	}
+	policy := getInput(maxTotalSizePolicyInput)
+	if policy == "" {
+		policy = maxTotalSizeFail
+	}
+	if policy != maxTotalSizeFail && policy != maxTotalSizeTrim {
+		invalidInput(fmt.Sprintf("input '%v' must be fail or trim, got '%v'", maxTotalSizePolicyInput, policy))
+	}
+	var total int64
+	var kept []string
+	for _, file := range files {
+		var size int64
+		if file != stdinFilename {
+			if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+				size = fi.Size()
+			}
+		}
+		if policy == maxTotalSizeTrim && total+size > limit {
+			githubactions.Warningf(fmt.Sprintf("%s (%s) does not fit in %v %v, skipping it", file, formatBytes(size), maxTotalSizeInput, v))
+			continue
+		}
+		total += size
+		kept = append(kept, file)
+	}
+	if total > limit {
+		fatalf(fmt.Sprintf("the matched files are %s in total, more than %v %v", formatBytes(total), maxTotalSizeInput, v))
+	}
+	fmt.Printf("Uploading %s of at most %s\n", formatBytes(total), formatBytes(limit))
+	return kept
+}
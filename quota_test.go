@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "100", want: 100},
+		{in: "0", want: 0},
+		{in: "100B", want: 100},
+		{in: "1.5KB", want: 1500},
+		{in: "1KiB", want: 1024},
+		{in: "500MB", want: 500 * 1000 * 1000},
+		{in: "16MiB", want: 16 << 20},
+		{in: "2GB", want: 2 * 1000 * 1000 * 1000},
+		{in: "2GiB", want: 2 << 30},
+		{in: "1TiB", want: 1 << 40},
+		{in: " 3 MB ", want: 3 * 1000 * 1000},
+		{in: "", wantErr: true},
+		{in: "MB", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "-1MB", wantErr: true},
+		{in: "5XB", wantErr: true},
+		{in: "5mb", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "InfGB", wantErr: true},
+		{in: "1e30TB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const fileRegistryInput = "fileRegistry"
+
+// fileRegistry records the ids of the files created by the action, by
+// destination folder and path. With the drive.file scope the action can
+// only find files created by the same credentials, so the registry lets it
+// overwrite files created by previous runs, e.g. with rotated keys.
+type fileRegistry struct {
+	Folders map[string]map[string]string `json:"folders"`
+}
+
+// registry is nil unless fileRegistry is set
+var registry *fileRegistry
+
+var registryFile string
+
+func loadRegistry() {
+	registryFile = getInput(fileRegistryInput)
+	if registryFile == "" {
+		return
+	}
+	registry = &fileRegistry{Folders: map[string]map[string]string{}}
+	data, err := os.ReadFile(registryFile)
+	if os.IsNotExist(err) {
+		fmt.Printf("No file registry found in %s\n", registryFile)
+		return
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading file registry from %s failed with error: %v", registryFile, err))
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		githubactions.Warningf(fmt.Sprintf("parsing file registry from %s failed with error: %v, ignoring it", registryFile, err))
+	}
+	if registry.Folders == nil {
+		registry.Folders = map[string]map[string]string{}
+	}
+}
+
+// lookup returns the registered file of a path if it still exists in the
+// expected folder, forgetting it otherwise
+func (r *fileRegistry) lookup(svc *drive.Service, folderId string, path string, parentId string) *drive.File {
+	if r == nil {
+		return nil
+	}
+	id := r.Folders[folderId][path]
+	if id == "" {
+		return nil
+	}
+	f, err := svc.Files.Get(id).Fields("name,id,mimeType,parents,version,md5Checksum,size,description,appProperties,trashed").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		if e, ok := err.(*googleapi.Error); !ok || e.Code != 404 {
+			fatalf(fmt.Sprintf("looking up registered file %s (%s) failed with error: %v", path, id, err))
+		}
+		f = nil
+	}
+	if f != nil && !f.Trashed {
+		for _, p := range f.Parents {
+			if p == parentId {
+				fmt.Printf("Found %s (%s) in the file registry\n", path, id)
+				return f
+			}
+		}
+	}
+	delete(r.Folders[folderId], path)
+	return nil
+}
+
+func (r *fileRegistry) record(folderId string, path string, fileId string) {
+	if r == nil {
+		return
+	}
+	if r.Folders[folderId] == nil {
+		r.Folders[folderId] = map[string]string{}
+	}
+	r.Folders[folderId][path] = fileId
+}
+
+func writeRegistry() {
+	if registry == nil {
+		return
+	}
+	data, err := json.Marshal(registry)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(registryFile), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(registryFile, data, 0644)
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing file registry to %s failed with error: %v", registryFile, err))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	replicasInput    = "replicas"
+	replicaCopyInput = "replicaCopy"
+)
+
+// replicaStatus is the outcome of replicating to an additional destination
+type replicaStatus struct {
+	FolderId    string `json:"folderId"`
+	Status      string `json:"status"`
+	FailedFiles int    `json:"failedFiles"`
+}
+
+// parseReplicas returns the ids of the additional destination folders or
+// shared drives, one per line
+func parseReplicas() []string {
+	var replicas []string
+	for _, line := range strings.Split(getInput(replicasInput), "\n") {
+		if id := strings.TrimSpace(line); id != "" {
+			replicas = append(replicas, id)
+		}
+	}
+	return replicas
+}
+
+func newReplicaPlanner(svc *drive.Service, folderId string, copyFiles bool) *planner {
+	p := newPlanner(svc, folderId)
+	p.plan.Replica = true
+	p.plan.Copy = copyFiles
+	return p
+}
+
+// applyReplicas applies the replica plans of a plan, once the files of the
+// primary destination with the given ids are uploaded, and exposes the status
+// of each replica as the 'replicas' output
+func applyReplicas(svc *drive.Service, pl *plan, ids map[string]string, verify bool) {
+	if len(pl.Replicas) == 0 {
+		return
+	}
+	statuses := []replicaStatus{}
+	for _, r := range pl.Replicas {
+		if r.Unplaced != "" {
+			before := len(failedFiles)
+			for _, op := range r.Operations {
+				if op.Action == actionCreate || op.Action == actionUpdate {
+					recordFailure(op, fmt.Errorf("replica %s: %s", r.FolderId, r.Unplaced))
+				}
+			}
+			quotaExceeded = true
+			statuses = append(statuses, replicaStatus{FolderId: r.FolderId, Status: "skipped", FailedFiles: len(failedFiles) - before})
+			continue
+		}
+		fmt.Printf("Replicating to folder %s\n", r.FolderId)
+		var copyFrom map[string]string
+		if r.Copy {
+			copyFrom = ids
+		}
+		before := len(failedFiles)
+		applyOperations(svc, r, verify, copyFrom)
+		status := replicaStatus{FolderId: r.FolderId, Status: "success", FailedFiles: len(failedFiles) - before}
+		if status.FailedFiles > 0 {
+			status.Status = "failure"
+		}
+		fmt.Printf("Replica %s: %s (%d failed file(s))\n", r.FolderId, status.Status, status.FailedFiles)
+		statuses = append(statuses, status)
+	}
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding replica status failed with error: %v", err))
+	}
+	githubactions.SetOutput("replicas", string(data))
+}
+
+// copyToDrive creates the file of an operation as a server-side copy of a
+// file already in Google Drive
+func copyToDrive(svc *drive.Service, sourceId string, folderId string, op operation) (*drive.File, error) {
+	f := &drive.File{
+		Name:          op.Name,
+		Description:   op.Description,
+		AppProperties: op.appProperties(),
+		Parents:       []string{folderId},
+	}
+	copied, err := svc.Files.Copy(sourceId, f).Fields("id,name,size").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return nil, fmt.Errorf("copying file %s failed with error: %w", sourceId, err)
+	}
+	fmt.Printf("Copied %s to %s (%s)\n", sourceId, copied.Name, copied.Id)
+	return copied, nil
+}
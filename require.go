@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const requireRemotePathInput = "requireRemotePath"
+
+// remotePathExists reports whether a path relative to the destination folder
+// exists. A path ending with a slash must be a folder.
+func remotePathExists(svc *drive.Service, folderId string, p string) bool {
+	folderOnly := strings.HasSuffix(p, "/")
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	id := folderId
+	for _, dir := range segments[:len(segments)-1] {
+		if id = findDriveDirectory(svc, id, nfc(dir)); id == "" {
+			return false
+		}
+	}
+	name := nfc(segments[len(segments)-1])
+	if folderOnly {
+		return findDriveDirectory(svc, id, name) != ""
+	}
+	return findDriveFileInFolder(svc, id, name) != nil
+}
+
+// requireRemotePaths fails the run before anything is uploaded if any of the
+// required paths, one per line, does not exist in the destination folder
+func requireRemotePaths(svc *drive.Service, folderId string) {
+	var missing []string
+	for _, line := range strings.Split(getInput(requireRemotePathInput), "\n") {
+		p := strings.TrimSpace(expandName(requireRemotePathInput, line, ""))
+		if p == "" {
+			continue
+		}
+		if !remotePathExists(svc, folderId, p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		fatalf(fmt.Sprintf("required path(s) not found in folder %s: %s", folderId, strings.Join(missing, ", ")))
+	}
+}
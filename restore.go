@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	restoreManifestInput = "restoreManifest"
+	restoreDirInput      = "restoreDir"
+)
+
+// readRestoreManifest reads a manifest from a local file, or from Google
+// Drive when no local file has the given name
+func readRestoreManifest(svc *drive.Service, source string) *manifest {
+	data, err := os.ReadFile(source)
+	if os.IsNotExist(err) {
+		fmt.Printf("No local file %s, downloading manifest %s from Google Drive\n", source, source)
+		var f *drive.File
+		f, err = svc.Files.Get(source).Fields("id,mimeType").SupportsAllDrives(allDrives).Do()
+		if err == nil {
+			data, err = downloadDriveFile(svc, f)
+		}
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading manifest %s failed with error: %v", source, err))
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		fatalf(fmt.Sprintf("parsing manifest %s failed with error: %v", source, err))
+	}
+	return &m
+}
+
+// restoreFiles downloads the files of a manifest into a directory under their
+// names in the manifest, verifying their hashes and restoring their mode
+func restoreFiles(svc *drive.Service, m *manifest, dir string) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		fatalf(fmt.Sprintf("resolving directory %s failed with error: %v", dir, err))
+	}
+	fmt.Printf("Restoring %d file(s) to %s\n", len(m.Files), root)
+	restored := 0
+	for _, e := range m.Files {
+		op := operation{Action: actionCreate, Path: e.Name, Name: e.Name, Source: e.FileId}
+		target := filepath.Join(root, filepath.FromSlash(e.Name))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			recordFailure(op, fmt.Errorf("%s is outside of %s", e.Name, root))
+			continue
+		}
+		if err := restoreFile(svc, e, target); err != nil {
+			recordFailure(op, err)
+			continue
+		}
+		fmt.Printf("Restored %s (%s)\n", e.Name, e.FileId)
+		restored++
+	}
+	githubactions.SetOutput("restoredFiles", strconv.Itoa(restored))
+}
+
+func restoreFile(svc *drive.Service, e manifestEntry, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	resp, err := svc.Files.Get(e.FileId).SupportsAllDrives(allDrives).Download()
+	if err != nil {
+		return fmt.Errorf("downloading %s (%s) failed with error: %w", e.Name, e.FileId, err)
+	}
+	defer resp.Body.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".gdrive-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	s, m := sha256.New(), md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, s, m), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("downloading %s (%s) failed with error: %w", e.Name, e.FileId, err)
+	}
+	switch {
+	case e.Sha256 != "" && hex.EncodeToString(s.Sum(nil)) != e.Sha256:
+		return fmt.Errorf("SHA-256 of %s (%s) does not match the manifest", e.Name, e.FileId)
+	case e.Md5 != "" && hex.EncodeToString(m.Sum(nil)) != e.Md5:
+		return fmt.Errorf("MD5 of %s (%s) does not match the manifest", e.Name, e.FileId)
+	}
+	if e.Mode != "" {
+		if mode, err := strconv.ParseUint(e.Mode, 8, 32); err == nil {
+			perm := os.FileMode(mode).Perm()
+			if mode&04000 != 0 {
+				perm |= os.ModeSetuid
+			}
+			if mode&02000 != 0 {
+				perm |= os.ModeSetgid
+			}
+			if mode&01000 != 0 {
+				perm |= os.ModeSticky
+			}
+			if err := os.Chmod(tmp.Name(), perm); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(tmp.Name(), target)
+}
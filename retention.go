@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	retainForInput           = "retainFor"
+	retentionHoldLabelsInput = "retentionHoldLabels"
+)
+
+// parseRetention returns how long files are kept by retainFor, and the ids of
+// the Drive labels exempting files from it
+func parseRetention() (time.Duration, []string) {
+	v := getInput(retainForInput)
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		invalidInput(fmt.Sprintf("input '%v' must be a positive duration, got '%v'", retainForInput, v))
+	}
+	var labels []string
+	for _, line := range strings.Split(getInput(retentionHoldLabelsInput), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			labels = append(labels, line)
+		}
+	}
+	return d, labels
+}
+
+// fileLabels returns the ids of the labels among labelIds a file carries.
+// The Drive client does not know labels, so they are requested directly.
+func fileLabels(svc *drive.Service, fileId string, labelIds []string) ([]string, error) {
+	u := fmt.Sprintf("%sfiles/%s?%s", svc.BasePath, url.PathEscape(fileId), url.Values{
+		"fields":            {"labelInfo(labels(id))"},
+		"includeLabels":     {strings.Join(labelIds, ",")},
+		"supportsAllDrives": {"true"},
+	}.Encode())
+	resp, err := driveClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	var f struct {
+		LabelInfo struct {
+			Labels []struct {
+				Id string `json:"id"`
+			} `json:"labels"`
+		} `json:"labelInfo"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, l := range f.LabelInfo.Labels {
+		ids = append(ids, l.Id)
+	}
+	return ids, nil
+}
+
+// pruneByRetention moves the files of the destination subtree created longer
+// ago than retainFor to the trash, or only lists them in a dry run. Files
+// carrying one of the retentionHoldLabels, e.g. a legal hold, are kept and
+// exposed as the 'protectedFiles' output. Folders are kept.
+func pruneByRetention(svc *drive.Service, folderId string, retainFor time.Duration, labels []string, dryRun bool) {
+	cutoff := time.Now().Add(-retainFor)
+	fmt.Printf("Looking for files in folder %s created before %s\n", folderId, cutoff.UTC().Format(time.RFC3339))
+	type match struct{ id, path string }
+	var matches []match
+	err := walkTree(svc, folderId, "id,name,mimeType,createdTime", func(folderPath string, f *drive.File) {
+		if f.MimeType == folderMimeType {
+			return
+		}
+		created, err := time.Parse(time.RFC3339, f.CreatedTime)
+		if err != nil || !created.Before(cutoff) {
+			return
+		}
+		matches = append(matches, match{f.Id, remotePath(folderPath, f.Name)})
+	})
+	if err != nil {
+		fatalf(err.Error())
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+	protected := []string{}
+	pruned := 0
+	for _, m := range matches {
+		if len(labels) > 0 {
+			held, err := fileLabels(svc, m.id, labels)
+			if err != nil {
+				// the file may be on hold, so it is not pruned
+				recordFailure(operation{Source: m.path, Path: m.path}, fmt.Errorf("reading the labels of %s failed with error: %w", m.id, err))
+				continue
+			}
+			if len(held) > 0 {
+				fmt.Printf("Keeping %s (%s), it carries label %s\n", m.path, m.id, strings.Join(held, ", "))
+				protected = append(protected, m.path)
+				continue
+			}
+		}
+		if dryRun {
+			fmt.Printf("Would move %s (%s) to the trash\n", m.path, m.id)
+			continue
+		}
+		if _, err := svc.Files.Update(m.id, &drive.File{Trashed: true}).Fields("id").SupportsAllDrives(allDrives).Do(); err != nil {
+			recordFailure(operation{Source: m.path, Path: m.path}, fmt.Errorf("moving %s to the trash failed with error: %w", m.id, err))
+			continue
+		}
+		fmt.Printf("Moved %s (%s) to the trash\n", m.path, m.id)
+		pruned++
+	}
+	fmt.Printf("%d file(s) past retention, %d protected by a label, %d moved to the trash\n", len(matches), len(protected), pruned)
+	data, err := json.Marshal(protected)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding protected files failed with error: %v", err))
+	}
+	githubactions.SetOutput("prunedFiles", fmt.Sprint(pruned))
+	githubactions.SetOutput("protectedFiles", string(data))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestFileLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{"no label", `{}`, nil},
+		{"held", `{"labelInfo": {"labels": [{"id": "legal-hold"}]}}`, []string{"legal-hold"}},
+		{"several", `{"labelInfo": {"labels": [{"id": "legal-hold"}, {"id": "audit"}]}}`, []string{"legal-hold", "audit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/files/abc" {
+					t.Errorf("requested %s, want /files/abc", r.URL.Path)
+				}
+				if got := r.URL.Query().Get("includeLabels"); got != "legal-hold,audit" {
+					t.Errorf("includeLabels = %q, want %q", got, "legal-hold,audit")
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+			driveClient = server.Client()
+			got, err := fileLabels(&drive.Service{BasePath: server.URL + "/"}, "abc", []string{"legal-hold", "audit"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fileLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileLabelsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 403, "message": "forbidden"}}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+	driveClient = server.Client()
+	if _, err := fileLabels(&drive.Service{BasePath: server.URL + "/"}, "abc", []string{"legal-hold"}); err == nil {
+		t.Error("fileLabels() did not fail on a 403")
+	}
+}
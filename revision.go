@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+const (
+	revisionMarkerInput      = "revisionMarker"
+	revisionMarkerFieldInput = "revisionMarkerField"
+
+	revisionProperty         = "revision"
+	revisionFieldProperty    = "property"
+	revisionFieldDescription = "description"
+)
+
+// markRevision labels the new revision of an overwritten file with the
+// expanded revisionMarker template, so that its revision history tells which
+// run produced each version
+func (op *operation) markRevision() {
+	value := getInput(revisionMarkerInput)
+	if value == "" {
+		return
+	}
+	marker := expandName(revisionMarkerInput, value, op.Source)
+	switch field := getInput(revisionMarkerFieldInput); field {
+	case "", revisionFieldProperty:
+		op.Revision = marker
+	case revisionFieldDescription:
+		if op.Description == "" {
+			op.Description = marker
+		} else {
+			op.Description += "\n" + marker
+		}
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", revisionMarkerFieldInput, revisionFieldProperty, revisionFieldDescription, field))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	pathRewriteInput   = "pathRewrite"
+	groupByTopDirInput = "groupByTopDir"
+)
+
+// rewriteRule maps local paths matching expr to remote paths
+type rewriteRule struct {
+	expr        *regexp.Regexp
+	replacement string
+}
+
+// parseRewriteRules parses one `regex => replacement` rule per line
+func parseRewriteRules(value string) []rewriteRule {
+	var rules []rewriteRule
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=>", 2)
+		if len(parts) != 2 {
+			invalidInput(fmt.Sprintf("invalid rule '%v' in input '%v', must be of the form 'regex => replacement'", line, pathRewriteInput))
+		}
+		expr, err := regexp.Compile(strings.TrimSpace(parts[0]))
+		if err != nil {
+			invalidInput(fmt.Sprintf("invalid regex in rule '%v' of input '%v': %v", line, pathRewriteInput, err))
+		}
+		rules = append(rules, rewriteRule{expr: expr, replacement: strings.TrimSpace(parts[1])})
+	}
+	return rules
+}
+
+var driveLetter = regexp.MustCompile(`^[A-Za-z]:`)
+
+// normalizePath turns a local path, possibly a Windows path with a drive
+// letter or mixed separators, into a clean slash separated relative path
+// used to compute the remote folder structure
+func normalizePath(p string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	p = driveLetter.ReplaceAllString(p, "")
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// rewritePath applies the first rule matching the slash separated path
+func rewritePath(rules []rewriteRule, path string) string {
+	for _, r := range rules {
+		if r.expr.MatchString(path) {
+			rewritten := strings.TrimPrefix(r.expr.ReplaceAllString(path, r.replacement), "/")
+			fmt.Printf("Rewriting path %s to %s\n", path, rewritten)
+			return rewritten
+		}
+	}
+	return path
+}
+
+// topDir returns the first directory of a matched path below the part of the
+// glob pattern without wildcards, e.g. shard-1 for test-results/shard-1/a.xml
+// matched by test-results/*/*.xml, or an empty string if there is none
+func topDir(pattern string, p string) string {
+	prefix := ""
+	for _, segment := range strings.Split(normalizePath(pattern), "/") {
+		if strings.ContainsAny(segment, `*?[`) {
+			break
+		}
+		prefix = path.Join(prefix, segment)
+	}
+	if prefix != "" && strings.HasPrefix(p, prefix+"/") {
+		p = strings.TrimPrefix(p, prefix+"/")
+	}
+	if dir := path.Dir(p); dir != "." {
+		return strings.Split(dir, "/")[0]
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"drive letter with mixed separators", `C:\a/b`, "a/b"},
+		{"repeated backslashes", `a\\b/c`, "a/b/c"},
+		{"unc path", `\\server\share\x`, "server/share/x"},
+		{"unc path with drive share", `\\server\c$\build\app.zip`, "server/c$/build/app.zip"},
+		{"dot segments", `.\build\..\out/app.zip`, "out/app.zip"},
+		{"leading parent segments", `../../out/app.zip`, "out/app.zip"},
+		{"already normalized", "build/out/app.zip", "build/out/app.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizePath(tt.in); got != tt.want {
+				t.Errorf("normalizePath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewritePath(t *testing.T) {
+	rules := parseRewriteRules(`
+# release builds go to their platform folder
+^target/release/ => linux-x64/
+^target/ => other/
+^docs/_build/html/(.*) => docs/${1}
+^build/(?P<rest>.*) => /${rest}`)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"prefix", "target/release/app", "linux-x64/app"},
+		{"first matching rule wins", "target/release/lib/a.so", "linux-x64/lib/a.so"},
+		{"later rule", "target/debug/app", "other/debug/app"},
+		{"numbered group", "docs/_build/html/index.html", "docs/index.html"},
+		{"named group with leading slash", "build/out/app.zip", "out/app.zip"},
+		{"no match", "src/main.go", "src/main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewritePath(rules, tt.in); got != tt.want {
+				t.Errorf("rewritePath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    string
+	}{
+		{"shard", "test-results/*/reports/*.xml", "test-results/shard-1/reports/junit.xml", "shard-1"},
+		{"matched by the part without wildcards", "test-results/*.xml", "test-results/junit.xml", ""},
+		{"no part without wildcards", "*/*.xml", "shard-1/junit.xml", "shard-1"},
+		{"windows pattern", `build\out\*\app.zip`, "build/out/arm64/app.zip", "arm64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topDir(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("topDir(%q, %q) = %q, want %q", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	routeByExtensionInput = "routeByExtension"
+	routeDefault          = "default"
+)
+
+// routeRule sends the files with an extension to another folder
+type routeRule struct {
+	ext      string
+	folderId string
+}
+
+func parseRoutes(value string) []routeRule {
+	var rules []routeRule
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=>", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			invalidInput(fmt.Sprintf("invalid rule '%v' in input '%v', must be of the form '.ext => folderId' or 'default => folderId'", line, routeByExtensionInput))
+		}
+		ext := strings.ToLower(strings.TrimSpace(parts[0]))
+		if ext != routeDefault && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		rules = append(rules, routeRule{ext: ext, folderId: strings.TrimSpace(parts[1])})
+	}
+	return rules
+}
+
+// routeFolder returns the id of the folder the first matching rule sends a
+// file to, the folder of the default rule when no extension matches, or an
+// empty string for the destination folder
+func routeFolder(rules []routeRule, file string) string {
+	name := strings.ToLower(file)
+	fallback := ""
+	for _, r := range rules {
+		if r.ext == routeDefault {
+			if fallback == "" {
+				fallback = r.folderId
+			}
+		} else if strings.HasSuffix(name, r.ext) {
+			return r.folderId
+		}
+	}
+	return fallback
+}
+
+// route returns the path folders of a routed file are planned under. Routed
+// folders are outside of the destination folder, so their path is their id
+// prefixed with @.
+func (p *planner) route(folderId string) string {
+	dir := "@" + folderId
+	if _, ok := p.folders[dir]; !ok {
+		p.folders[dir] = folderId
+		if p.plan.Routes == nil {
+			p.plan.Routes = map[string]string{}
+		}
+		p.plan.Routes[dir] = folderId
+	}
+	return dir
+}
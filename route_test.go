@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestRouteFolder(t *testing.T) {
+	rules := parseRoutes(`
+default => other
+.tar.gz => archives
+.GZ => compressed
+apk => android`)
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"build/app.apk", "android"},
+		{"dist/app.tar.gz", "archives"},
+		{"logs/app.log.gz", "compressed"},
+		{"BUILD/APP.APK", "android"},
+		{"README.md", "other"},
+		{"build/apk", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := routeFolder(rules, tt.file); got != tt.want {
+				t.Errorf("routeFolder(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+	if got := routeFolder(parseRoutes(".apk => android"), "README.md"); got != "" {
+		t.Errorf("routeFolder without default = %q, want the destination folder", got)
+	}
+}
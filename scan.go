@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	virusScanInput       = "virusScan"
+	virusScanActionInput = "virusScanAction"
+	virusScanClamav      = "clamav"
+	virusScanActionFail  = "fail"
+	virusScanActionSkip  = "skip"
+	clamscanBatchSize    = 500
+	scanResultClean      = "clean"
+)
+
+// scanResults are the results of the virus scan recorded in the manifest, by
+// source file
+var scanResults = map[string]string{}
+
+// infectedFile is a file the virus scan detected something in
+type infectedFile struct {
+	Path      string `json:"path"`
+	Signature string `json:"signature"`
+}
+
+// scanFiles runs the virus scanner over the files, failing or skipping the
+// files it detects something in. The scanner is ClamAV or a command prefixed
+// with sh:, run for each file given as GDRIVE_UPLOAD_FILE, exiting with 1
+// and printing what it detected for infected files.
+func scanFiles(files []string) []string {
+	scanner := getInput(virusScanInput)
+	if scanner == "" {
+		return files
+	}
+	action := getInput(virusScanActionInput)
+	if action == "" {
+		action = virusScanActionFail
+	}
+	if action != virusScanActionFail && action != virusScanActionSkip {
+		invalidInput(fmt.Sprintf("input '%v' must be %v or %v, got '%v'", virusScanActionInput, virusScanActionFail, virusScanActionSkip, action))
+	}
+	var toScan []string
+	for _, file := range files {
+		if file != stdinFilename {
+			toScan = append(toScan, file)
+		}
+	}
+	var found map[string]string
+	var err error
+	if command := strings.TrimPrefix(scanner, shellTransform); command != scanner {
+		found, err = scanWithCommand(strings.TrimSpace(command), toScan)
+	} else if scanner == virusScanClamav {
+		found, err = scanWithClamav(toScan)
+	} else {
+		invalidInput(fmt.Sprintf("input '%v' must be %v or a command prefixed with %v, got '%v'", virusScanInput, virusScanClamav, shellTransform, scanner))
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("scanning files for viruses failed with error: %v", err))
+	}
+	fmt.Printf("Scanned %d file(s) for viruses, %d infected\n", len(toScan), len(found))
+
+	infected := []infectedFile{}
+	var clean []string
+	for _, file := range files {
+		signature, ok := found[file]
+		switch {
+		case file == stdinFilename:
+			clean = append(clean, file)
+		case ok:
+			githubactions.Errorf(fmt.Sprintf("%s is infected: %s", file, signature))
+			infected = append(infected, infectedFile{Path: file, Signature: signature})
+		default:
+			scanResults[file] = scanResultClean
+			clean = append(clean, file)
+		}
+	}
+	data, _ := json.Marshal(infected)
+	githubactions.SetOutput("infectedFiles", string(data))
+	if len(infected) > 0 && action == virusScanActionFail {
+		fatalf(fmt.Sprintf("the virus scan detected %d infected file(s), nothing was uploaded", len(infected)))
+	}
+	return clean
+}
+
+// scanWithClamav runs clamscan over the files in batches, returning the
+// signatures found by file
+func scanWithClamav(files []string) (map[string]string, error) {
+	found := map[string]string{}
+	abs := map[string]string{}
+	for start := 0; start < len(files); start += clamscanBatchSize {
+		end := start + clamscanBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+		args := []string{"--no-summary", "--infected", "--stdout", "--"}
+		for _, file := range files[start:end] {
+			a, err := filepath.Abs(file)
+			if err != nil {
+				return nil, err
+			}
+			abs[a] = file
+			args = append(args, a)
+		}
+		var out bytes.Buffer
+		cmd := exec.Command("clamscan", args...)
+		cmd.Stdout, cmd.Stderr = &out, os.Stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		// clamscan exits with 1 when it found viruses
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return nil, fmt.Errorf("clamscan: %v", err)
+		}
+		for _, line := range strings.Split(out.String(), "\n") {
+			line = strings.TrimSuffix(strings.TrimSpace(line), " FOUND")
+			i := strings.LastIndex(line, ": ")
+			if i < 0 {
+				continue
+			}
+			if file, ok := abs[line[:i]]; ok {
+				found[file] = line[i+2:]
+			}
+		}
+	}
+	return found, nil
+}
+
+// scanWithCommand runs a command for each file
+func scanWithCommand(command string, files []string) (map[string]string, error) {
+	found := map[string]string{}
+	for _, file := range files {
+		var out bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdout, cmd.Stderr = &out, os.Stderr
+		cmd.Env = append(os.Environ(), "GDRIVE_UPLOAD_FILE="+file)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+			signature := strings.TrimSpace(out.String())
+			if signature == "" {
+				signature = "detected by " + command
+			}
+			found[file] = signature
+		default:
+			return nil, fmt.Errorf("scanning %s: %v", file, err)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const tempDirInput = "tempDir"
+
+// tempDir is the directory temporary files are written to, the default
+// temporary directory of the system if empty
+var tempDir string
+
+func parseTempDir() {
+	tempDir = getInput(tempDirInput)
+	if tempDir == "" {
+		return
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		fatalf(fmt.Sprintf("creating temporary directory %s failed with error: %v", tempDir, err))
+	}
+}
+
+// checkScratchSpace fails early if the transformed copies of the files may
+// not fit in the temporary directory, instead of running out of space in the
+// middle of the run. Each copy is assumed to be as large as its file, which
+// holds for compression and minification.
+func checkScratchSpace(files []string, transforms []transformRule) {
+	if len(transforms) == 0 {
+		return
+	}
+	var required int64
+	for _, file := range files {
+		if file == stdinFilename {
+			continue
+		}
+		for _, r := range transforms {
+			if matchesPattern(r.pattern, file) {
+				if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+					required += fi.Size()
+				}
+				break
+			}
+		}
+	}
+	dir := tempDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	available, ok := freeSpace(dir)
+	if !ok {
+		return
+	}
+	fmt.Printf("Transformed files need up to %s in %s, %s available\n", formatBytes(required), dir, formatBytes(int64(available)))
+	if uint64(required) > available {
+		fatalf(fmt.Sprintf("transformed files need up to %s in %s but only %s are available. free up space or set '%v' to a larger disk", formatBytes(required), dir, formatBytes(int64(available)), tempDirInput))
+	}
+}
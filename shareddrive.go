@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	sharedDriveSupportInput = "sharedDriveSupport"
+	sharedDriveSupportAuto  = "auto"
+	sharedDriveSupportOn    = "on"
+	sharedDriveSupportOff   = "off"
+)
+
+var sharedDriveSupport = sharedDriveSupportAuto
+
+// allDrives is whether requests support and include the items of shared
+// drives
+var allDrives = true
+
+func parseSharedDriveSupport() {
+	if v := getInput(sharedDriveSupportInput); v != "" {
+		sharedDriveSupport = v
+	}
+	switch sharedDriveSupport {
+	case sharedDriveSupportAuto, sharedDriveSupportOn:
+		allDrives = true
+	case sharedDriveSupportOff:
+		allDrives = false
+	default:
+		invalidInput(fmt.Sprintf("input '%v' must be one of auto, on or off, got '%v'", sharedDriveSupportInput, sharedDriveSupport))
+	}
+}
+
+// detectSharedDriveSupport turns shared drive support off in auto mode when
+// a listing of all drives is rejected, as required by some Workspace
+// policies for My Drive only setups
+func detectSharedDriveSupport(svc *drive.Service) {
+	if sharedDriveSupport != sharedDriveSupportAuto {
+		return
+	}
+	_, err := svc.Files.List().Fields("files(id)").PageSize(1).IncludeItemsFromAllDrives(true).Corpora("allDrives").SupportsAllDrives(true).Do()
+	if e, ok := err.(*googleapi.Error); ok && (e.Code == 400 || e.Code == 403) {
+		githubactions.Warningf(fmt.Sprintf("listing all drives was rejected with error: %v. turning shared drive support off, set '%v' to silence this warning", err, sharedDriveSupportInput))
+		allDrives = false
+	}
+}
+
+// corpora returns the bodies of items searched by listings
+func corpora() string {
+	if allDrives {
+		return "allDrives"
+	}
+	return "user"
+}
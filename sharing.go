@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	enforcePermissionsInput = "enforcePermissions"
+	permissionsPolicyInput  = "permissionsPolicy"
+
+	enforceAudit = "audit"
+)
+
+// permissionChange is a difference between the permissions of a file and the
+// policy, and what is done to converge
+type permissionChange struct {
+	FileId string `json:"fileId"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	Email  string `json:"emailAddress,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Role   string `json:"role"`
+}
+
+// permissionKey identifies who a permission is granted to
+func permissionKey(p *drive.Permission) string {
+	return p.Type + ":" + strings.ToLower(p.EmailAddress+p.Domain)
+}
+
+func parsePermissionsPolicy(value string) []*drive.Permission {
+	var policy []*drive.Permission
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		fatalf(fmt.Sprintf("parsing input '%v' failed with error: %v", permissionsPolicyInput, err))
+	}
+	for _, p := range policy {
+		if p.Type == "" || p.Role == "" {
+			invalidInput(fmt.Sprintf("every permission of input '%v' needs a type and a role", permissionsPolicyInput))
+		}
+	}
+	return policy
+}
+
+// isManaged reports whether a permission is subject to the policy. Owners and
+// permissions inherited from a shared drive or parent folder are left alone.
+func isManaged(p *drive.Permission) bool {
+	if p.Role == "owner" {
+		return false
+	}
+	for _, d := range p.PermissionDetails {
+		if !d.Inherited {
+			return true
+		}
+	}
+	return len(p.PermissionDetails) == 0
+}
+
+// diffPermissions returns the changes converging the permissions of a file to
+// the policy
+func diffPermissions(fileId string, name string, current []*drive.Permission, policy []*drive.Permission) []permissionChange {
+	var changes []permissionChange
+	existing := map[string]*drive.Permission{}
+	for _, p := range current {
+		existing[permissionKey(p)] = p
+	}
+	wanted := map[string]bool{}
+	for _, p := range policy {
+		key := permissionKey(p)
+		wanted[key] = true
+		c := permissionChange{FileId: fileId, Name: name, Type: p.Type, Email: p.EmailAddress, Domain: p.Domain, Role: p.Role}
+		if e, ok := existing[key]; !ok {
+			c.Action = "add"
+		} else if e.Role != p.Role && isManaged(e) {
+			c.Action = "update"
+		} else {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	for _, p := range current {
+		if !wanted[permissionKey(p)] && isManaged(p) {
+			changes = append(changes, permissionChange{FileId: fileId, Name: name, Action: "remove", Type: p.Type, Email: p.EmailAddress, Domain: p.Domain, Role: p.Role})
+		}
+	}
+	return changes
+}
+
+func listPermissions(svc *drive.Service, fileId string) ([]*drive.Permission, error) {
+	var permissions []*drive.Permission
+	pageToken := ""
+	for {
+		r, err := svc.Permissions.List(fileId).Fields("nextPageToken,permissions(id,type,role,emailAddress,domain,permissionDetails)").PageToken(pageToken).SupportsAllDrives(allDrives).Do()
+		if err != nil {
+			return nil, fmt.Errorf("listing permissions failed with error: %w", err)
+		}
+		permissions = append(permissions, r.Permissions...)
+		if r.NextPageToken == "" {
+			return permissions, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+func applyPermissionChange(svc *drive.Service, c permissionChange, current []*drive.Permission) error {
+	var id string
+	for _, p := range current {
+		if permissionKey(p) == permissionKey(&drive.Permission{Type: c.Type, EmailAddress: c.Email, Domain: c.Domain}) {
+			id = p.Id
+		}
+	}
+	var err error
+	switch c.Action {
+	case "add":
+		p := &drive.Permission{Type: c.Type, Role: c.Role, EmailAddress: c.Email, Domain: c.Domain}
+		call := svc.Permissions.Create(c.FileId, p).Fields("id").SupportsAllDrives(allDrives)
+		if c.Type == "user" || c.Type == "group" {
+			call = call.SendNotificationEmail(false)
+		}
+		_, err = call.Do()
+	case "update":
+		_, err = svc.Permissions.Update(c.FileId, id, &drive.Permission{Role: c.Role}).Fields("id").SupportsAllDrives(allDrives).Do()
+	case "remove":
+		err = svc.Permissions.Delete(c.FileId, id).SupportsAllDrives(allDrives).Do()
+	}
+	return err
+}
+
+// enforcePermissions diffs the permissions of the destination folder and of
+// the uploaded files against the policy, and converges them unless only
+// auditing. The differences are exposed as the 'permissionChanges' output.
+func enforcePermissions(svc *drive.Service, pl *plan) {
+	mode := getInput(enforcePermissionsInput)
+	if mode == "" || mode == "false" {
+		return
+	}
+	if mode != "true" && mode != enforceAudit {
+		invalidInput(fmt.Sprintf("invalid value '%v' for input '%v', must be true, false or %v", mode, enforcePermissionsInput, enforceAudit))
+	}
+	policyValue := getInput(permissionsPolicyInput)
+	if policyValue == "" {
+		missingInput(permissionsPolicyInput)
+	}
+	policy := parsePermissionsPolicy(policyValue)
+
+	targets := []manifestEntry{{Name: "destination folder", FileId: pl.FolderId}}
+	targets = append(targets, runManifest.Files...)
+	changes := []permissionChange{}
+	for _, t := range targets {
+		op := operation{Source: "permissions", Path: t.Name}
+		current, err := listPermissions(svc, t.FileId)
+		if err != nil {
+			recordFailure(op, err)
+			continue
+		}
+		for _, c := range diffPermissions(t.FileId, t.Name, current, policy) {
+			changes = append(changes, c)
+			who := c.Email + c.Domain
+			if who == "" {
+				who = c.Type
+			}
+			if mode == enforceAudit {
+				githubactions.Warningf(fmt.Sprintf("%s: %s permission of %s as %s (audit only)", t.Name, c.Action, who, c.Role))
+				continue
+			}
+			fmt.Printf("%s: %s permission of %s as %s\n", t.Name, c.Action, who, c.Role)
+			if err := applyPermissionChange(svc, c, current); err != nil {
+				recordFailure(op, fmt.Errorf("%s permission of %s failed with error: %w", c.Action, who, err))
+			}
+		}
+	}
+	fmt.Printf("%d permission change(s) on %d file(s)\n", len(changes), len(targets))
+	data, err := json.Marshal(changes)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding permission changes failed with error: %v", err))
+	}
+	githubactions.SetOutput("permissionChanges", string(data))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestDiffPermissions(t *testing.T) {
+	inherited := []*drive.PermissionPermissionDetails{{Inherited: true}}
+	tests := []struct {
+		name    string
+		current []*drive.Permission
+		policy  []*drive.Permission
+		want    []permissionChange
+	}{
+		{
+			name:   "add",
+			policy: []*drive.Permission{{Type: "domain", Domain: "example.com", Role: "reader"}},
+			want:   []permissionChange{{FileId: "f", Name: "a.zip", Action: "add", Type: "domain", Domain: "example.com", Role: "reader"}},
+		},
+		{
+			name:    "converged, domains and emails ignore case",
+			current: []*drive.Permission{{Type: "domain", Domain: "Example.com", Role: "reader"}},
+			policy:  []*drive.Permission{{Type: "domain", Domain: "example.com", Role: "reader"}},
+		},
+		{
+			name:    "role change",
+			current: []*drive.Permission{{Type: "user", EmailAddress: "a@example.com", Role: "writer"}},
+			policy:  []*drive.Permission{{Type: "user", EmailAddress: "a@example.com", Role: "reader"}},
+			want:    []permissionChange{{FileId: "f", Name: "a.zip", Action: "update", Type: "user", Email: "a@example.com", Role: "reader"}},
+		},
+		{
+			name:    "remove",
+			current: []*drive.Permission{{Type: "anyone", Role: "reader"}},
+			want:    []permissionChange{{FileId: "f", Name: "a.zip", Action: "remove", Type: "anyone", Role: "reader"}},
+		},
+		{
+			name:    "owners are kept",
+			current: []*drive.Permission{{Type: "user", EmailAddress: "owner@example.com", Role: "owner"}},
+		},
+		{
+			name:    "inherited permissions are kept",
+			current: []*drive.Permission{{Type: "user", EmailAddress: "a@example.com", Role: "writer", PermissionDetails: inherited}},
+			policy:  []*drive.Permission{{Type: "user", EmailAddress: "a@example.com", Role: "reader"}},
+		},
+		{
+			name: "direct permissions also inherited are removed",
+			current: []*drive.Permission{{Type: "user", EmailAddress: "a@example.com", Role: "writer", PermissionDetails: []*drive.PermissionPermissionDetails{
+				{Inherited: true}, {Inherited: false},
+			}}},
+			want: []permissionChange{{FileId: "f", Name: "a.zip", Action: "remove", Type: "user", Email: "a@example.com", Role: "writer"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffPermissions("f", "a.zip", tt.current, tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffPermissions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcePermissions(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"true", []string{"DELETE /files/folder/permissions/anyone", "PATCH /files/folder/permissions/user", "POST /files/folder/permissions"}},
+		{enforceAudit, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var changes []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "GET" {
+					json.NewEncoder(w).Encode(drive.PermissionList{Permissions: []*drive.Permission{
+						{Id: "owner", Type: "user", EmailAddress: "owner@example.com", Role: "owner"},
+						{Id: "user", Type: "user", EmailAddress: "a@example.com", Role: "writer"},
+						{Id: "anyone", Type: "anyone", Role: "reader"},
+					}})
+					return
+				}
+				changes = append(changes, r.Method+" "+r.URL.Path)
+				w.Write([]byte(`{"id": "new"}`))
+			}))
+			defer server.Close()
+			svc, err := drive.New(server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			svc.BasePath = server.URL + "/"
+			os.Setenv("INPUT_ENFORCEPERMISSIONS", tt.mode)
+			os.Setenv("INPUT_PERMISSIONSPOLICY", `[{"type": "user", "emailAddress": "a@example.com", "role": "reader"}, {"type": "domain", "domain": "example.com", "role": "reader"}]`)
+			defer os.Unsetenv("INPUT_ENFORCEPERMISSIONS")
+			defer os.Unsetenv("INPUT_PERMISSIONSPOLICY")
+
+			enforcePermissions(svc, &plan{FolderId: "folder"})
+			sort.Strings(changes)
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes = %q, want %q", changes, tt.want)
+			}
+			if len(failedFiles) > 0 {
+				t.Errorf("failures: %+v", failedFiles)
+			}
+		})
+	}
+}
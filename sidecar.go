@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	metadataSidecarsInput = "metadataSidecars"
+	sidecarSuffix         = ".meta.json"
+)
+
+var metadataSidecars bool
+
+// sidecar is the metadata of an uploaded file that Google Drive does not
+// store, uploaded next to it as <name>.meta.json
+type sidecar struct {
+	Name       string `json:"name"`
+	Source     string `json:"source,omitempty"`
+	FileId     string `json:"fileId"`
+	Size       int64  `json:"size,omitempty"`
+	Sha256     string `json:"sha256,omitempty"`
+	Md5        string `json:"md5,omitempty"`
+	Modified   string `json:"modified,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Uid        string `json:"uid,omitempty"`
+	Gid        string `json:"gid,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Workflow   string `json:"workflow,omitempty"`
+	Sha        string `json:"sha,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	RunId      string `json:"runId,omitempty"`
+	Actor      string `json:"actor,omitempty"`
+	Uploaded   string `json:"uploaded"`
+}
+
+// uploadSidecar uploads the metadata of a file uploaded by op next to it,
+// updating the sidecar left by a previous run if there is one
+func uploadSidecar(svc *drive.Service, op operation, parentId string, fileId string) error {
+	s := sidecar{
+		Name:       op.Name,
+		Source:     op.Source,
+		FileId:     fileId,
+		Size:       op.Size,
+		Sha256:     op.Sha256,
+		Md5:        op.Md5,
+		Mode:       op.Mode,
+		Uid:        op.Uid,
+		Gid:        op.Gid,
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		Workflow:   os.Getenv("GITHUB_WORKFLOW"),
+		Sha:        os.Getenv("GITHUB_SHA"),
+		Ref:        os.Getenv("GITHUB_REF"),
+		RunId:      os.Getenv("GITHUB_RUN_ID"),
+		Actor:      os.Getenv("GITHUB_ACTOR"),
+		Uploaded:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if op.Source != stdinFilename {
+		if fi, err := os.Stat(op.Source); err == nil {
+			s.Modified = fi.ModTime().UTC().Format(time.RFC3339)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tempDir, "gdrive-upload-sidecar-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	tmp.Close()
+
+	name := op.Name + sidecarSuffix
+	existing := findDriveFileInFolder(svc, parentId, name)
+	if _, err := uploadToDrive(svc, tmp.Name(), parentId, existing, name, "application/json", "", nil); err != nil {
+		return fmt.Errorf("uploading metadata sidecar %s failed with error: %w", name, err)
+	}
+	return nil
+}
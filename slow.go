@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const fileTimeoutInput = "fileTimeout"
+
+// fileTimeout is the time budget of the upload of a single file, or 0
+var fileTimeout time.Duration
+
+// slowFiles are the files skipped because their upload exceeded fileTimeout
+// twice
+var slowFiles = []string{}
+
+func parseFileTimeout() {
+	v := getInput(fileTimeoutInput)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		invalidInput(fmt.Sprintf("invalid duration for input '%v': %v", fileTimeoutInput, err))
+	}
+	fileTimeout = d
+}
+
+// fileContext is the context of the upload of a single file, cancelled once
+// it exceeds fileTimeout
+func fileContext() (context.Context, context.CancelFunc) {
+	if fileTimeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), fileTimeout)
+}
+
+// outputSlowFiles exposes the files skipped for being slow as the
+// 'slowFiles' output
+func outputSlowFiles() {
+	if fileTimeout == 0 {
+		return
+	}
+	data, err := json.Marshal(slowFiles)
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding slow files failed with error: %v", err))
+	}
+	githubactions.SetOutput("slowFiles", string(data))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	treeSnapshotInput = "treeSnapshot"
+	// maxSummaryEntries bounds the table added to the job summary, which is
+	// limited to 1MiB
+	maxSummaryEntries = 500
+)
+
+// snapshotEntry is a file or folder of the destination tree
+type snapshotEntry struct {
+	Path     string `json:"path"`
+	Id       string `json:"id"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size,omitempty"`
+	Md5      string `json:"md5,omitempty"`
+	Modified string `json:"modified,omitempty"`
+	Link     string `json:"link,omitempty"`
+}
+
+type treeSnapshot struct {
+	FolderId string          `json:"folderId"`
+	Created  string          `json:"created"`
+	Files    []snapshotEntry `json:"files"`
+}
+
+// writeTreeSnapshot walks the destination tree and writes its files, sorted
+// by path, as JSON to the path given by treeSnapshot and as a table to the
+// job summary, so that two publishing runs can be compared
+func writeTreeSnapshot(svc *drive.Service, folderId string) {
+	file := getInput(treeSnapshotInput)
+	if file == "" {
+		return
+	}
+	snapshot := treeSnapshot{FolderId: folderId, Created: time.Now().UTC().Format(time.RFC3339), Files: []snapshotEntry{}}
+	err := walkTree(svc, folderId, "id,name,mimeType,size,md5Checksum,modifiedTime,webViewLink", func(folderPath string, f *drive.File) {
+		snapshot.Files = append(snapshot.Files, snapshotEntry{
+			Path:     remotePath(folderPath, f.Name),
+			Id:       f.Id,
+			MimeType: f.MimeType,
+			Size:     f.Size,
+			Md5:      f.Md5Checksum,
+			Modified: f.ModifiedTime,
+			Link:     f.WebViewLink,
+		})
+	})
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("taking a snapshot of folder %s failed: %v", folderId, err))
+		return
+	}
+	sort.Slice(snapshot.Files, func(i, j int) bool { return snapshot.Files[i].Path < snapshot.Files[j].Path })
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(file), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(file, data, 0644)
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing snapshot to %s failed with error: %v", file, err))
+		return
+	}
+	fmt.Printf("Snapshot of %d file(s) and folder(s) written to %s\n", len(snapshot.Files), file)
+
+	if os.Getenv("GITHUB_STEP_SUMMARY") == "" {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Google Drive folder %s\n\n", folderId)
+	b.WriteString("| Path | Size | MD5 | Modified |\n|---|---|---|---|\n")
+	for i, e := range snapshot.Files {
+		if i == maxSummaryEntries {
+			fmt.Fprintf(&b, "\n%d more in %s\n", len(snapshot.Files)-maxSummaryEntries, file)
+			break
+		}
+		name := strings.ReplaceAll(e.Path, "|", `\|`)
+		if e.Link != "" {
+			name = fmt.Sprintf("[%s](%s)", name, e.Link)
+		}
+		if e.MimeType == folderMimeType {
+			name += "/"
+		}
+		size := ""
+		if e.MimeType != folderMimeType {
+			size = formatBytes(e.Size)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", name, size, e.Md5, e.Modified)
+	}
+	b.WriteString("\n")
+	appendStepSummary(b.String())
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const excludeMimeTypesInput = "excludeMimeTypes"
+
+// sniffMimeType detects the MIME type of a file from its content. On top of
+// the types known to net/http, executables and libraries are detected.
+func sniffMimeType(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return elfMimeType(f), nil
+	case bytes.HasPrefix(head, []byte{0xfe, 0xed, 0xfa, 0xce}), bytes.HasPrefix(head, []byte{0xfe, 0xed, 0xfa, 0xcf}),
+		bytes.HasPrefix(head, []byte{0xce, 0xfa, 0xed, 0xfe}), bytes.HasPrefix(head, []byte{0xcf, 0xfa, 0xed, 0xfe}):
+		return "application/x-mach-binary", nil
+	case bytes.HasPrefix(head, []byte("MZ")):
+		return "application/vnd.microsoft.portable-executable", nil
+	}
+	return http.DetectContentType(head), nil
+}
+
+// elfMimeType tells executables, including position independent ones, from
+// shared libraries, objects and core dumps
+func elfMimeType(r io.ReaderAt) string {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return "application/x-elf"
+	}
+	switch f.Type {
+	case elf.ET_EXEC:
+		return "application/x-executable"
+	case elf.ET_DYN:
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_INTERP {
+				return "application/x-executable"
+			}
+		}
+		return "application/x-sharedlib"
+	case elf.ET_REL:
+		return "application/x-object"
+	case elf.ET_CORE:
+		return "application/x-coredump"
+	}
+	return "application/x-elf"
+}
+
+// excludeByMimeType drops the files whose sniffed MIME type matches one of
+// the patterns of excludeMimeTypes, e.g. application/x-executable or video/*
+func excludeByMimeType(files []string) []string {
+	var patterns []string
+	for _, line := range strings.Split(getInput(excludeMimeTypesInput), "\n") {
+		for _, pattern := range strings.Split(line, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				if _, err := path.Match(pattern, ""); err != nil {
+					invalidInput(fmt.Sprintf("invalid pattern '%v' in input '%v': %v", pattern, excludeMimeTypesInput, err))
+				}
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return files
+	}
+	var kept []string
+	for _, file := range files {
+		if file == stdinFilename {
+			kept = append(kept, file)
+			continue
+		}
+		if fi, err := os.Stat(file); err == nil && !fi.Mode().IsRegular() {
+			kept = append(kept, file)
+			continue
+		}
+		mimeType, err := sniffMimeType(file)
+		if err != nil {
+			fatalf(fmt.Sprintf("reading file %s failed with error: %v", file, err))
+		}
+		// parameters such as the charset are not part of the type
+		mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+		excluded := false
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, mimeType); ok {
+				fmt.Printf("%s: detected as %s, matching '%s' of %s, skipping\n", file, mimeType, pattern, excludeMimeTypesInput)
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const checkFreeSpaceInput = "checkFreeSpace"
+
+// placement is where the files of a run are placed, recorded in the manifest
+// when the free space of the destinations is checked
+type placement struct {
+	FolderId string `json:"folderId"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// plannedBytes returns the number of files and bytes a plan uploads or
+// copies
+func plannedBytes(pl *plan) (int, int64) {
+	files := 0
+	var bytes int64
+	for _, op := range pl.Operations {
+		if op.Action != actionCreate && op.Action != actionUpdate {
+			continue
+		}
+		files++
+		size := op.Size
+		if size == 0 && op.Source != stdinFilename {
+			if fi, err := os.Stat(op.content()); err == nil {
+				size = fi.Size()
+			}
+		}
+		bytes += size
+	}
+	return files, bytes
+}
+
+// storagePool returns the pool of storage files in a folder count against
+// and its free space, or -1 when it is not limited. Files in shared drives
+// count against the organization, whose quota cannot be looked up, and
+// files in My Drive against the quota of the account.
+func storagePool(svc *drive.Service, folderId string) (string, int64, error) {
+	f, err := svc.Files.Get(folderId).Fields("driveId").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return "", 0, err
+	}
+	if f.DriveId != "" {
+		return f.DriveId, -1, nil
+	}
+	about, err := svc.About.Get().Fields("storageQuota(limit,usage)").Do()
+	if err != nil {
+		return "", 0, err
+	}
+	if about.StorageQuota.Limit == 0 {
+		return "", -1, nil
+	}
+	return "", about.StorageQuota.Limit - about.StorageQuota.Usage, nil
+}
+
+// checkFreeSpace checks that every destination of a plan has room for its
+// files before anything is uploaded. The run fails when the primary
+// destination is full, while replicas without room are skipped, their files
+// recorded as failed.
+func checkFreeSpace(svc *drive.Service, pl *plan) {
+	if !getBoolInput(checkFreeSpaceInput) {
+		return
+	}
+	free := map[string]int64{}
+	checked := map[string]bool{}
+	for _, p := range append([]*plan{pl}, pl.Replicas...) {
+		files, bytes := plannedBytes(p)
+		pool, available, err := storagePool(svc, p.FolderId)
+		if err != nil {
+			fail(exitFailure, fmt.Sprintf("looking up the free space of folder %s failed with error: %v", p.FolderId, err))
+		}
+		if !checked[pool] {
+			checked[pool] = true
+			free[pool] = available
+		}
+		place := placement{FolderId: p.FolderId, Files: files, Bytes: bytes, Status: "placed"}
+		switch {
+		case free[pool] < 0:
+			fmt.Printf("Folder %s: %s to upload, free space not limited\n", p.FolderId, formatBytes(bytes))
+		case bytes <= free[pool]:
+			fmt.Printf("Folder %s: %s to upload, %s free\n", p.FolderId, formatBytes(bytes), formatBytes(free[pool]))
+			free[pool] -= bytes
+		case !p.Replica:
+			fail(exitQuota, fmt.Sprintf("folder %s needs %s but only %s are free, nothing was uploaded", p.FolderId, formatBytes(bytes), formatBytes(free[pool])))
+		default:
+			place.Status = "skipped"
+			place.Reason = fmt.Sprintf("needs %s but only %s are free", formatBytes(bytes), formatBytes(free[pool]))
+			p.Unplaced = place.Reason
+			fmt.Printf("Folder %s: %s, skipping the replica\n", p.FolderId, place.Reason)
+		}
+		runManifest.Placements = append(runManifest.Placements, place)
+	}
+}
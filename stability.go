@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	stabilityWindowInput = "stabilityWindow"
+	maxStabilityChecks   = 3
+)
+
+// stableFiles returns the files whose size and modification time did not
+// change during the window, checking the files still changing again up to
+// maxStabilityChecks times. Files still being written after that are
+// skipped, so that no partially written file is uploaded.
+func stableFiles(files []string, window time.Duration) []string {
+	type fingerprint struct {
+		size    int64
+		modTime time.Time
+	}
+	stat := func(file string) (fingerprint, error) {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return fingerprint{}, err
+		}
+		return fingerprint{fi.Size(), fi.ModTime()}, nil
+	}
+	last := map[string]fingerprint{}
+	var pending []string
+	for _, file := range files {
+		if file == stdinFilename {
+			continue
+		}
+		fp, err := stat(file)
+		if err != nil {
+			fatalf(fmt.Sprintf("reading file %s failed with error: %v", file, err))
+		}
+		last[file] = fp
+		pending = append(pending, file)
+	}
+	changing := map[string]bool{}
+	for check := 1; check <= maxStabilityChecks && len(pending) > 0; check++ {
+		fmt.Printf("Waiting %v for %d file(s) to stop changing\n", window, len(pending))
+		time.Sleep(window)
+		var still []string
+		for _, file := range pending {
+			fp, err := stat(file)
+			if err != nil {
+				fatalf(fmt.Sprintf("reading file %s failed with error: %v", file, err))
+			}
+			if fp != last[file] {
+				last[file] = fp
+				still = append(still, file)
+			}
+		}
+		pending = still
+	}
+	for _, file := range pending {
+		githubactions.Warningf(fmt.Sprintf("%s is still being written after %d checks of %v, skipping it", file, maxStabilityChecks, window))
+		changing[file] = true
+	}
+	var stable []string
+	for _, file := range files {
+		if !changing[file] {
+			stable = append(stable, file)
+		}
+	}
+	return stable
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	skipUploadedInput = "skipUploaded"
+	stateFileInput    = "stateFile"
+	defaultStateFile  = ".gdrive-upload/state.json"
+)
+
+// uploadedFile is the fingerprint of a file uploaded by an attempt of a run
+type uploadedFile struct {
+	Target     string `json:"target"`
+	FolderId   string `json:"folderId"`
+	FileId     string `json:"fileId"`
+	Size       int64  `json:"size"`
+	Sha256     string `json:"sha256"`
+	RunAttempt string `json:"runAttempt"`
+}
+
+// uploadState records the files uploaded by the attempts of a run, by source
+// path, so that later attempts can skip them
+type uploadState struct {
+	RunId string                  `json:"runId"`
+	Files map[string]uploadedFile `json:"files"`
+}
+
+// state is nil unless skipUploaded is enabled
+var state *uploadState
+
+var stateFile string
+
+// fileHash is the fingerprint of the content of a local file
+type fileHash struct {
+	Sha256 string
+	Md5    string
+	Size   int64
+}
+
+// hashFile returns the fingerprint of a file, computed ahead by the pipeline
+// when it prepared the file
+func hashFile(path string) (fileHash, error) {
+	if h, err, ok := pipeline.hash(path); ok {
+		return h, err
+	}
+	return computeHash(path)
+}
+
+func computeHash(path string) (fileHash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileHash{}, err
+	}
+	defer f.Close()
+	s, m := sha256.New(), md5.New()
+	n, err := io.Copy(io.MultiWriter(s, m), f)
+	if err != nil {
+		return fileHash{}, err
+	}
+	return fileHash{Sha256: hex.EncodeToString(s.Sum(nil)), Md5: hex.EncodeToString(m.Sum(nil)), Size: n}, nil
+}
+
+func loadUploadState(path string) {
+	runId := os.Getenv("GITHUB_RUN_ID")
+	state = &uploadState{RunId: runId, Files: map[string]uploadedFile{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		fatalf(fmt.Sprintf("reading upload state from %s failed with error: %v", path, err))
+	}
+	var previous uploadState
+	if err := json.Unmarshal(data, &previous); err != nil {
+		fatalf(fmt.Sprintf("parsing upload state from %s failed with error: %v", path, err))
+	}
+	if previous.RunId != runId {
+		fmt.Printf("Upload state in %s is from another run (%s), ignoring it\n", path, previous.RunId)
+		return
+	}
+	if previous.Files != nil {
+		state.Files = previous.Files
+	}
+	fmt.Printf("Loaded %d uploaded file(s) from previous attempts\n", len(state.Files))
+}
+
+// isUploaded reports whether an earlier attempt already uploaded the same
+// content to the same target
+func (s *uploadState) isUploaded(op operation, folderId string) bool {
+	if s == nil || op.Sha256 == "" {
+		return false
+	}
+	u, ok := s.Files[op.Source]
+	return ok && u.Target == op.Path && u.FolderId == folderId && u.Size == op.Size && u.Sha256 == op.Sha256
+}
+
+func (s *uploadState) record(op operation, folderId string, fileId string) {
+	if s == nil || op.Sha256 == "" {
+		return
+	}
+	s.Files[op.Source] = uploadedFile{
+		Target:     op.Path,
+		FolderId:   folderId,
+		FileId:     fileId,
+		Size:       op.Size,
+		Sha256:     op.Sha256,
+		RunAttempt: os.Getenv("GITHUB_RUN_ATTEMPT"),
+	}
+}
+
+func writeUploadState() {
+	if state == nil {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(stateFile), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(stateFile, data, 0644)
+	}
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("writing upload state to %s failed with error: %v", stateFile, err))
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	statusNameInput = "statusName"
+
+	statusInProgress = "in-progress"
+	statusComplete   = "complete"
+	statusFailed     = "failed"
+)
+
+// runStatus is written to the destination folder so that external consumers
+// can tell whether a run is still publishing to it
+type runStatus struct {
+	State          string `json:"state"`
+	Repository     string `json:"repository,omitempty"`
+	Sha            string `json:"sha,omitempty"`
+	RunId          string `json:"runId,omitempty"`
+	Started        string `json:"started"`
+	Finished       string `json:"finished,omitempty"`
+	Files          int    `json:"files"`
+	FailedFiles    int    `json:"failedFiles"`
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// manifestDigest is the SHA-256 of the manifest uploaded by the run
+var manifestDigest string
+
+// writeStatus writes the state of the run to the status file given by
+// statusName. The complete state carries the digest of the manifest, or of
+// the list of uploaded files when no manifest is uploaded.
+func writeStatus(svc *drive.Service, pl *plan, state string) {
+	name := getInput(statusNameInput)
+	if name == "" {
+		return
+	}
+	status := runStatus{
+		State:          state,
+		Repository:     os.Getenv("GITHUB_REPOSITORY"),
+		Sha:            os.Getenv("GITHUB_SHA"),
+		RunId:          os.Getenv("GITHUB_RUN_ID"),
+		Started:        stats.start.UTC().Format(time.RFC3339),
+		IdempotencyKey: idempotencyKey(),
+	}
+	if state != statusInProgress {
+		status.Finished = time.Now().UTC().Format(time.RFC3339)
+		status.Files = len(runManifest.Files)
+		status.FailedFiles = len(failedFiles)
+		status.ManifestDigest = manifestDigest
+		if status.ManifestDigest == "" {
+			data, _ := json.Marshal(runManifest.Files)
+			sum := sha256.Sum256(data)
+			status.ManifestDigest = hex.EncodeToString(sum[:])
+		}
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return
+	}
+	op := operation{Action: actionCreate, Path: name, Name: name, Source: "status"}
+	tmp, err := os.CreateTemp(tempDir, "gdrive-upload-status-*.json")
+	if err != nil {
+		recordFailure(op, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	tmp.Close()
+
+	existing := findDriveFileInFolder(svc, pl.FolderId, name)
+	if existing != nil {
+		op.Action = actionUpdate
+	}
+	fmt.Printf("Setting %s to %s\n", name, state)
+	if _, err := uploadToDrive(svc, tmp.Name(), pl.FolderId, existing, name, "application/json", "", nil); err != nil {
+		recordFailure(op, err)
+	}
+}
+
+// finalStatus is the state of a run that applied its plan
+func finalStatus() string {
+	if len(failedFiles) > 0 {
+		return statusFailed
+	}
+	return statusComplete
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	followSymlinksInput      = "followSymlinks"
+	symlinksAsShortcutsInput = "symlinksAsShortcuts"
+	shortcutMimeType         = "application/vnd.google-apps.shortcut"
+
+	actionShortcut = "shortcut"
+)
+
+// symlinkTargets maps the symlinks among files to the file of the run they
+// point to, or to an empty string when they point outside of the run
+func symlinkTargets(files []string) map[string]string {
+	real := map[string]string{}
+	links := map[string]string{}
+	for _, file := range files {
+		if file == stdinFilename {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			resolved = file
+		}
+		resolved, _ = filepath.Abs(resolved)
+		if fi, err := os.Lstat(file); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			links[file] = resolved
+		} else {
+			real[resolved] = file
+		}
+	}
+	for link, resolved := range links {
+		links[link] = real[resolved]
+	}
+	return links
+}
+
+// addShortcut plans a shortcut named like the symlink file, pointing at the
+// file uploaded from target. An existing file with the same name is left
+// alone.
+func (p *planner) addShortcut(file string, target string, dirs []string, name string) {
+	fmt.Printf("target shortcut name: %s -> %s\n", name, target)
+	folder, parentId := p.resolveFolder(dirs)
+	op := operation{
+		Action:   actionShortcut,
+		Path:     remotePath(folder, name),
+		Folder:   folder,
+		Name:     name,
+		Source:   file,
+		Target:   target,
+		ParentId: parentId,
+		FileId:   p.fileIds[target],
+		Reason:   "file is a symlink",
+	}
+	if parentId != "" {
+		if existing := p.findFileInFolder(parentId, name); existing != nil {
+			fmt.Printf("%s already exists (%s), not creating a shortcut\n", op.Path, existing.Id)
+			return
+		}
+	}
+	op.Precondition = &precondition{Absent: true}
+	p.plan.Operations = append(p.plan.Operations, op)
+}
+
+func createShortcut(svc *drive.Service, parentId string, name string, targetId string, appProperties map[string]string) (*drive.File, error) {
+	f := &drive.File{
+		Name:            name,
+		MimeType:        shortcutMimeType,
+		Parents:         []string{parentId},
+		ShortcutDetails: &drive.FileShortcutDetails{TargetId: targetId},
+		AppProperties:   appProperties,
+	}
+	created, err := svc.Files.Create(f).Fields("id,name").SupportsAllDrives(allDrives).Do()
+	if err != nil {
+		return nil, fmt.Errorf("creating shortcut failed with error: %w", err)
+	}
+	fmt.Printf("Created shortcut %s (%s) to %s\n", created.Name, created.Id, targetId)
+	return created, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// telemetry collects a span per uploaded file and exports them, along with
+// upload metrics, to an OTLP/HTTP endpoint configured through the standard
+// OTEL_EXPORTER_OTLP_* environment variables
+type telemetry struct {
+	tracesEndpoint  string
+	metricsEndpoint string
+	headers         map[string]string
+	traceId         string
+	root            otlpSpan
+	spans           []otlpSpan
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+var otel *telemetry
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) otlpAttribute {
+	v := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &v}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func otlpEndpoint(signal string) string {
+	if e := os.Getenv("OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT"); e != "" {
+		return e
+	}
+	if e := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); e != "" {
+		return strings.TrimSuffix(e, "/") + "/v1/" + signal
+	}
+	return ""
+}
+
+// initTelemetry enables the export when an OTLP endpoint is configured
+func initTelemetry() {
+	traces, metrics := otlpEndpoint("traces"), otlpEndpoint("metrics")
+	if traces == "" && metrics == "" {
+		return
+	}
+	headers := map[string]string{}
+	for _, h := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) == 2 {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	traceId := randomHex(16)
+	otel = &telemetry{
+		tracesEndpoint:  traces,
+		metricsEndpoint: metrics,
+		headers:         headers,
+		traceId:         traceId,
+		root: otlpSpan{
+			TraceId:           traceId,
+			SpanId:            randomHex(8),
+			Name:              "gdrive-upload",
+			Kind:              1,
+			StartTimeUnixNano: unixNano(stats.start),
+		},
+	}
+	fmt.Println("OpenTelemetry export enabled")
+}
+
+// recordUpload records the span of a single file upload, sent again retries
+// times
+func (t *telemetry) recordUpload(op operation, size int64, start time.Time, retries int, err error) {
+	if t == nil {
+		return
+	}
+	end := time.Now()
+	result := "success"
+	status := otlpStatus{Code: 1}
+	if err != nil {
+		result = "failure"
+		status = otlpStatus{Code: 2, Message: err.Error()}
+	}
+	t.spans = append(t.spans, otlpSpan{
+		TraceId:           t.traceId,
+		SpanId:            randomHex(8),
+		ParentSpanId:      t.root.SpanId,
+		Name:              "upload " + op.Path,
+		Kind:              3,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes: []otlpAttribute{
+			stringAttribute("gdrive.action", op.Action),
+			stringAttribute("gdrive.source", op.Source),
+			stringAttribute("gdrive.path", op.Path),
+			stringAttribute("gdrive.result", result),
+			intAttribute("gdrive.size", size),
+			intAttribute("retries", int64(retries)),
+		},
+		Status: status,
+	})
+}
+
+func (t *telemetry) resource() map[string]interface{} {
+	attributes := []otlpAttribute{stringAttribute("service.name", "gdrive-upload")}
+	if s := os.Getenv("OTEL_SERVICE_NAME"); s != "" {
+		attributes[0] = stringAttribute("service.name", s)
+	}
+	for _, env := range []string{"GITHUB_REPOSITORY", "GITHUB_WORKFLOW", "GITHUB_RUN_ID", "GITHUB_RUN_ATTEMPT", "GITHUB_SHA"} {
+		if v := os.Getenv(env); v != "" {
+			attributes = append(attributes, stringAttribute("github."+strings.ToLower(strings.TrimPrefix(env, "GITHUB_")), v))
+		}
+	}
+	return map[string]interface{}{"attributes": attributes}
+}
+
+func (t *telemetry) post(endpoint string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// export sends the collected spans and metrics. Failing to export never fails
+// the upload.
+func (t *telemetry) export() {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.root.EndTimeUnixNano = unixNano(now)
+	t.root.Status = otlpStatus{Code: 1}
+	if stats.files["failure"] > 0 {
+		t.root.Status = otlpStatus{Code: 2, Message: fmt.Sprintf("%d upload(s) failed", stats.files["failure"])}
+	}
+	scope := map[string]string{"name": "gdrive-upload"}
+
+	if t.tracesEndpoint != "" {
+		payload := map[string]interface{}{
+			"resourceSpans": []interface{}{map[string]interface{}{
+				"resource": t.resource(),
+				"scopeSpans": []interface{}{map[string]interface{}{
+					"scope": scope,
+					"spans": append([]otlpSpan{t.root}, t.spans...),
+				}},
+			}},
+		}
+		if err := t.post(t.tracesEndpoint, payload); err != nil {
+			githubactions.Warningf(fmt.Sprintf("exporting traces failed with error: %v", err))
+		}
+	}
+
+	if t.metricsEndpoint != "" {
+		start, end := t.root.StartTimeUnixNano, unixNano(now)
+		sum := func(name string, unit string, points []map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"name": name,
+				"unit": unit,
+				"sum": map[string]interface{}{
+					"dataPoints":             points,
+					"aggregationTemporality": 2,
+					"isMonotonic":            true,
+				},
+			}
+		}
+		point := func(value interface{}, attributes ...otlpAttribute) map[string]interface{} {
+			p := map[string]interface{}{"startTimeUnixNano": start, "timeUnixNano": end}
+			switch v := value.(type) {
+			case int64:
+				p["asInt"] = strconv.FormatInt(v, 10)
+			case float64:
+				p["asDouble"] = v
+			}
+			if len(attributes) > 0 {
+				p["attributes"] = attributes
+			}
+			return p
+		}
+		var filePoints []map[string]interface{}
+		for result, n := range stats.files {
+			filePoints = append(filePoints, point(n, stringAttribute("result", result)))
+		}
+		payload := map[string]interface{}{
+			"resourceMetrics": []interface{}{map[string]interface{}{
+				"resource": t.resource(),
+				"scopeMetrics": []interface{}{map[string]interface{}{
+					"scope": scope,
+					"metrics": []interface{}{
+						sum("gdrive.upload.files", "{file}", filePoints),
+						sum("gdrive.upload.bytes", "By", []map[string]interface{}{point(stats.bytes)}),
+						sum("gdrive.upload.duration", "s", []map[string]interface{}{point(stats.duration.Seconds())}),
+					},
+				}},
+			}},
+		}
+		if err := t.post(t.metricsEndpoint, payload); err != nil {
+			githubactions.Warningf(fmt.Sprintf("exporting metrics failed with error: %v", err))
+		}
+	}
+}
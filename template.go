@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	templateFolderIdInput   = "templateFolderId"
+	templateFolderNameInput = "templateFolderName"
+)
+
+// instantiateTemplate copies the subfolders and files of a template folder
+// into a new folder of the destination, named after the templateFolderName
+// input, and returns the id of the folder to upload to. Folders and files
+// that already exist by name are kept, so re-running a release does not
+// duplicate the scaffolding or overwrite documents edited since. A dry run
+// only logs the folders and files that would be copied, and returns an empty
+// id when the new folder does not exist yet.
+func instantiateTemplate(svc *drive.Service, templateId string, folderId string, dryRun bool) string {
+	if name := expandName(templateFolderNameInput, getInput(templateFolderNameInput), ""); name != "" {
+		var id string
+		var err error
+		if dryRun {
+			if id = findDriveDirectory(svc, folderId, name); id == "" {
+				fmt.Printf("Would create folder %s\n", name)
+			}
+		} else if id, err = createDriveDirectory(svc, folderId, name); err != nil {
+			fatalf(fmt.Sprintf("creating folder %s failed with error: %v", name, err))
+		}
+		folderId = id
+	}
+	fmt.Printf("Instantiating template folder %s in %s\n", templateId, folderId)
+	folders := map[string]string{"": folderId}
+	// names are the names of the files in the destination folders, listed
+	// when a file is first copied into the folder
+	names := map[string]map[string]bool{}
+	namesIn := func(id string) map[string]bool {
+		if id == "" {
+			// the folder does not exist yet in a dry run
+			return map[string]bool{}
+		}
+		if n, ok := names[id]; ok {
+			return n
+		}
+		n := map[string]bool{}
+		children, err := listChildren(svc, id, "id,name")
+		if err != nil {
+			fatalf(fmt.Sprintf("listing folder %s failed with error: %v", id, err))
+		}
+		for _, child := range children {
+			n[nfc(child.Name)] = true
+		}
+		names[id] = n
+		return n
+	}
+	var copied, kept int
+	err := walkTree(svc, templateId, "id,name,mimeType,description", func(folderPath string, f *drive.File) {
+		parentId, ok := folders[folderPath]
+		if !ok {
+			// the folder failed to be created
+			return
+		}
+		p := remotePath(folderPath, f.Name)
+		op := operation{Action: actionCreate, Path: p, Folder: folderPath, Name: f.Name, Source: f.Id, ParentId: parentId, Description: f.Description}
+		if f.MimeType == folderMimeType && dryRun {
+			id := ""
+			if parentId != "" {
+				id = findDriveDirectory(svc, parentId, f.Name)
+			}
+			if id == "" {
+				fmt.Printf("Would create template folder %s\n", p)
+			}
+			folders[p] = id
+			return
+		}
+		if f.MimeType == folderMimeType {
+			id, err := createDriveDirectory(svc, parentId, f.Name)
+			if err != nil {
+				recordFailure(op, err)
+				return
+			}
+			folders[p] = id
+			return
+		}
+		if namesIn(parentId)[nfc(f.Name)] {
+			kept++
+			return
+		}
+		if dryRun {
+			fmt.Printf("Would copy template file %s\n", p)
+			copied++
+			return
+		}
+		c, err := svc.Files.Copy(f.Id, &drive.File{
+			Name:        f.Name,
+			Description: f.Description,
+			Parents:     []string{parentId},
+		}).Fields("id,name").SupportsAllDrives(allDrives).Do()
+		if err != nil {
+			recordFailure(op, fmt.Errorf("copying template file %s failed with error: %w", f.Id, err))
+			return
+		}
+		fmt.Printf("Copied template file %s (%s)\n", p, c.Id)
+		copied++
+	})
+	if err != nil {
+		fatalf(err.Error())
+	}
+	if dryRun {
+		fmt.Printf("Would copy %d template file(s), %d already existing\n", copied, kept)
+		return folderId
+	}
+	fmt.Printf("Copied %d template file(s), %d already existing\n", copied, kept)
+	githubactions.SetOutput("templateFolderUrl", folderLink(folderId))
+	return folderId
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	waitForPreviewsInput = "waitForPreviews"
+	maxPreviewsDelay     = 30 * time.Second
+)
+
+var previewsTimeout time.Duration
+
+// hasPreview reports whether Drive renders a preview of files of a MIME type
+func hasPreview(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "image/") || mimeType == "application/pdf"
+}
+
+// previewReady reports whether Drive finished processing the preview of a
+// file. Videos have no media metadata until they can be played.
+func previewReady(f *drive.File) bool {
+	if strings.HasPrefix(f.MimeType, "video/") {
+		return f.VideoMediaMetadata != nil && f.VideoMediaMetadata.DurationMillis > 0
+	}
+	return f.HasThumbnail
+}
+
+// waitForPreviews polls the uploaded videos, images and PDFs until Drive
+// finished processing their previews and thumbnails, for at most the
+// duration given by waitForPreviews, so that links shared right after the
+// run do not open a broken preview. Files whose preview cannot be checked
+// are not polled again. The status of each file is added to the job summary
+// by its path.
+func waitForPreviews(svc *drive.Service) {
+	timeout := previewsTimeout
+	if timeout == 0 || len(runManifest.Files) == 0 {
+		return
+	}
+	status := map[string]string{}
+	pending := map[string]string{}
+	for _, e := range runManifest.Files {
+		pending[e.FileId] = e.Path
+	}
+	fmt.Printf("Waiting up to %v for the previews of the uploaded files\n", timeout)
+	deadline := time.Now().Add(timeout)
+	delay := time.Second
+	for {
+		for id, p := range pending {
+			f, err := svc.Files.Get(id).Fields("id,mimeType,hasThumbnail,videoMediaMetadata(durationMillis)").SupportsAllDrives(allDrives).Do()
+			switch {
+			case err != nil:
+				githubactions.Warningf(fmt.Sprintf("checking the preview of %s (%s) failed with error: %v", p, id, err))
+				status[p] = "error"
+				delete(pending, id)
+			case !hasPreview(f.MimeType):
+				delete(pending, id)
+			case previewReady(f):
+				status[p] = "ready"
+				delete(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			fmt.Println("No preview is processing anymore")
+			break
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			for _, p := range pending {
+				status[p] = "still processing"
+			}
+			githubactions.Warningf(fmt.Sprintf("the previews of %d uploaded file(s) are still processing after %v", len(pending), timeout))
+			break
+		}
+		wait := delay
+		if wait > left {
+			// check once more at the deadline
+			wait = left
+		}
+		fmt.Printf("%d preview(s) still processing, checking again in %s\n", len(pending), formatDuration(wait))
+		time.Sleep(wait)
+		if delay *= 2; delay > maxPreviewsDelay {
+			delay = maxPreviewsDelay
+		}
+	}
+	if len(status) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(status))
+	for p := range status {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	b.WriteString("### Previews\n\n| File | Preview |\n|---|---|\n")
+	for _, p := range paths {
+		fmt.Fprintf(&b, "| %s | %s |\n", p, status[p])
+	}
+	appendStepSummary(b.String() + "\n")
+}
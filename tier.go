@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	resumableThresholdInput = "resumableThreshold"
+	uploadChunkSizeInput    = "uploadChunkSize"
+)
+
+// resumableThreshold is the size from which files are sent with a resumable
+// upload, smaller files are sent in a single request
+var resumableThreshold int64 = googleapi.DefaultUploadChunkSize
+
+// uploadChunkSize is the size of the chunks of resumable uploads
+var uploadChunkSize = googleapi.DefaultUploadChunkSize
+
+func parseUploadTiers() {
+	if v := getInput(resumableThresholdInput); v != "" {
+		n, err := parseSize(v)
+		if err != nil {
+			invalidInput(fmt.Sprintf("input '%v' must be a size, e.g. 5MB, got '%v'", resumableThresholdInput, v))
+		}
+		resumableThreshold = n
+	}
+	if v := getInput(uploadChunkSizeInput); v != "" {
+		n, err := parseSize(v)
+		if err != nil || n < googleapi.MinUploadChunkSize {
+			invalidInput(fmt.Sprintf("input '%v' must be a size of at least 256KiB, e.g. 64MiB, got '%v'", uploadChunkSizeInput, v))
+		}
+		uploadChunkSize = int(n)
+	}
+}
+
+// mediaOptions picks how a file of the given size is uploaded. Files below
+// the threshold are sent in one request with their metadata, larger files
+// and streams of unknown size with a resumable upload in chunks, which Drive
+// only uses when there is more than one chunk.
+func mediaOptions(size int64, known bool) googleapi.MediaOption {
+	if known && size < resumableThreshold {
+		return googleapi.ChunkSize(0)
+	}
+	return googleapi.ChunkSize(uploadChunkSize)
+}
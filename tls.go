@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	caBundleInput           = "caBundle"
+	insecureSkipVerifyInput = "insecureSkipVerify"
+)
+
+// configureTLS trusts the certificates of caBundle on top of the system ones,
+// e.g. of a TLS intercepting corporate proxy, for every request of the run,
+// by replacing the default transport
+func configureTLS() {
+	bundle := getInput(caBundleInput)
+	insecure := getBoolInput(insecureSkipVerifyInput)
+	if bundle == "" && !insecure {
+		return
+	}
+	config := &tls.Config{}
+	if bundle != "" {
+		pem := []byte(bundle)
+		// the bundle is either the path of a PEM file or its content
+		if !strings.Contains(bundle, "-----BEGIN") {
+			data, err := os.ReadFile(bundle)
+			if err != nil {
+				fatalf(fmt.Sprintf("reading CA bundle %s failed with error: %v", bundle, err))
+			}
+			pem = data
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			invalidInput(fmt.Sprintf("input '%v' contains no PEM certificate", caBundleInput))
+		}
+		config.RootCAs = pool
+	}
+	if insecure {
+		githubactions.Warningf(fmt.Sprintf("'%v' is enabled: the certificates of Google servers are not verified, anyone on the network path can read the credentials and files. prefer '%v'", insecureSkipVerifyInput, caBundleInput))
+		config.InsecureSkipVerify = true
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	http.DefaultTransport = transport
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	transformInput    = "transform"
+	gzipPatternsInput = "gzipPatterns"
+	shellTransform    = "sh:"
+)
+
+// transformRule applies a transform to the files matching a pattern
+type transformRule struct {
+	pattern   string
+	transform string
+}
+
+// builtinTransforms write the transformed content of a file, returning the
+// suffix added to its name
+var builtinTransforms = map[string]func(in *os.File, out *os.File) (string, error){
+	"gzip": func(in *os.File, out *os.File) (string, error) {
+		zw := gzip.NewWriter(out)
+		zw.Name = filepath.Base(in.Name())
+		if _, err := io.Copy(zw, in); err != nil {
+			return "", err
+		}
+		return ".gz", zw.Close()
+	},
+	"minifyJson": func(in *os.File, out *os.File) (string, error) {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		if err := json.Compact(&b, data); err != nil {
+			return "", err
+		}
+		_, err = out.Write(b.Bytes())
+		return "", err
+	},
+	"strip": func(in *os.File, out *os.File) (string, error) {
+		if output, err := exec.Command("strip", "-o", out.Name(), in.Name()).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+		}
+		return "", nil
+	},
+}
+
+// transformed maps the source files to the temporary copies uploaded instead
+var transformed = map[string]string{}
+
+var transformDir string
+
+// transformMu guards transformed and transformDir, as files are transformed
+// concurrently by the pipeline
+var transformMu sync.Mutex
+
+// parseTransforms parses the transform rules, one 'pattern => transform' per
+// line. A transform is a built-in one or a shell command prefixed with sh:.
+func parseTransforms(value string) []transformRule {
+	var rules []transformRule
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=>", 2)
+		if len(parts) != 2 {
+			invalidInput(fmt.Sprintf("invalid rule '%v' in input '%v', must be of the form 'pattern => transform'", line, transformInput))
+		}
+		r := transformRule{pattern: strings.TrimSpace(parts[0]), transform: strings.TrimSpace(parts[1])}
+		if _, err := path.Match(r.pattern, ""); err != nil {
+			invalidInput(fmt.Sprintf("invalid pattern in rule '%v' of input '%v': %v", line, transformInput, err))
+		}
+		if _, ok := builtinTransforms[r.transform]; !ok && !strings.HasPrefix(r.transform, shellTransform) {
+			invalidInput(fmt.Sprintf("unknown transform '%v' in input '%v', must be gzip, minifyJson, strip or a command prefixed with %v", r.transform, transformInput, shellTransform))
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// gzipRules are the rules gzipping the files matching the patterns, one per
+// line
+func gzipRules(value string) []transformRule {
+	var rules []transformRule
+	for _, line := range strings.Split(value, "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			invalidInput(fmt.Sprintf("invalid pattern '%v' in input '%v': %v", pattern, gzipPatternsInput, err))
+		}
+		rules = append(rules, transformRule{pattern: pattern, transform: "gzip"})
+	}
+	return rules
+}
+
+// matchesPattern matches a glob pattern against the normalized path of a file
+// or its name
+func matchesPattern(pattern string, file string) bool {
+	p := normalizePath(file)
+	if ok, _ := path.Match(pattern, p); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(p))
+	return ok
+}
+
+// transformFile applies the first matching rule to a copy of a file, leaving
+// the file untouched, and returns the suffix added to its name
+func transformFile(rules []transformRule, file string) string {
+	for _, r := range rules {
+		if !matchesPattern(r.pattern, file) {
+			continue
+		}
+		var err error
+		transformMu.Lock()
+		if transformDir == "" {
+			if transformDir, err = os.MkdirTemp(tempDir, "gdrive-upload-transform-"); err != nil {
+				fatalf(fmt.Sprintf("creating temporary directory failed with error: %v", err))
+			}
+		}
+		dir := transformDir
+		transformMu.Unlock()
+		in, err := os.Open(file)
+		if os.IsNotExist(err) && onMissing == onMissingSkip {
+			// skipped when planned
+			return ""
+		}
+		if err != nil {
+			fatalf(fmt.Sprintf("opening file %s failed with error: %v", file, err))
+		}
+		defer in.Close()
+		out, err := os.CreateTemp(dir, "*-"+filepath.Base(file))
+		if err != nil {
+			fatalf(fmt.Sprintf("creating temporary file failed with error: %v", err))
+		}
+		defer out.Close()
+
+		fmt.Printf("Transforming %s with %s\n", file, r.transform)
+		var suffix string
+		if command := strings.TrimPrefix(r.transform, shellTransform); command != r.transform {
+			cmd := exec.Command("sh", "-c", strings.TrimSpace(command))
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, os.Stderr
+			cmd.Env = append(os.Environ(), "GDRIVE_UPLOAD_FILE="+file)
+			err = cmd.Run()
+		} else {
+			suffix, err = builtinTransforms[r.transform](in, out)
+		}
+		if err != nil {
+			fatalf(fmt.Sprintf("transforming %s with %s failed with error: %v", file, r.transform, err))
+		}
+		transformMu.Lock()
+		transformed[file] = out.Name()
+		transformMu.Unlock()
+		return suffix
+	}
+	return ""
+}
+
+// hashContent returns the fingerprint of what is uploaded for a file
+func hashContent(file string) (fileHash, error) {
+	if t := transformedCopy(file); t != "" {
+		return hashFile(t)
+	}
+	return index.hashFile(file)
+}
+
+// transformedCopy returns the transformed copy of a file, if any
+func transformedCopy(file string) string {
+	transformMu.Lock()
+	defer transformMu.Unlock()
+	return transformed[file]
+}
+
+// content is the path of the file uploaded by an operation
+func (op operation) content() string {
+	if op.Upload != "" {
+		return op.Upload
+	}
+	return op.Source
+}
+
+func removeTransformed() {
+	if transformDir != "" {
+		os.RemoveAll(transformDir)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	usageReportInput = "usageReport"
+	usageBudgetInput = "usageBudget"
+)
+
+// usageReport computes the total size and number of files in the subtree of
+// the destination folder after the run, exposed as the 'folderSize' and
+// 'folderFileCount' outputs, and warns when the size is over usageBudget.
+// Google Docs count with the storage they use.
+func usageReport(svc *drive.Service, folderId string) {
+	if !getBoolInput(usageReportInput) {
+		return
+	}
+	var budget int64
+	if v := getInput(usageBudgetInput); v != "" {
+		n, err := parseSize(v)
+		if err != nil {
+			invalidInput(fmt.Sprintf("input '%v' must be a size, e.g. 10GB, got '%v'", usageBudgetInput, v))
+		}
+		budget = n
+	}
+	var size, files, folders int64
+	err := walkTree(svc, folderId, "id,name,mimeType,size,quotaBytesUsed", func(folderPath string, f *drive.File) {
+		if f.MimeType == folderMimeType {
+			folders++
+			return
+		}
+		files++
+		if f.Size > 0 {
+			size += f.Size
+		} else {
+			size += f.QuotaBytesUsed
+		}
+	})
+	if err != nil {
+		githubactions.Warningf(fmt.Sprintf("computing the usage of folder %s failed: %v", folderId, err))
+		return
+	}
+	fmt.Printf("Folder %s holds %d file(s) in %d folder(s), %s\n", folderId, files, folders, formatBytes(size))
+	githubactions.SetOutput("folderSize", strconv.FormatInt(size, 10))
+	githubactions.SetOutput("folderFileCount", strconv.FormatInt(files, 10))
+	if budget > 0 && size > budget {
+		githubactions.Warningf(fmt.Sprintf("folder %s holds %s, more than %v %v", folderId, formatBytes(size), usageBudgetInput, getInput(usageBudgetInput)))
+	}
+}
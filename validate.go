@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	actionInput    = "action"
+	actionUpload   = "upload"
+	actionValidate = "validate"
+	schemaId       = "https://raw.githubusercontent.com/adityak74/google-drive-upload-git-action/main/gdrive-upload.schema.json"
+)
+
+// profileForbidden are the inputs that cannot be set in a profile
+var profileForbidden = map[string]bool{
+	credentialsInput: true,
+	profileInput:     true,
+	configFileInput:  true,
+}
+
+// validateConfigFile checks a config file against the schema, returning
+// the problems found with the path of the offending values
+func validateConfigFile(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return []string{fmt.Sprintf("$: %s", describeJsonError(data, err))}
+	}
+	var problems []string
+	for k := range top {
+		if k != "$schema" && k != "profiles" {
+			problems = append(problems, fmt.Sprintf("$.%s: unknown key, must be profiles", k))
+		}
+	}
+	var profiles map[string]json.RawMessage
+	if raw, ok := top["profiles"]; ok {
+		if err := json.Unmarshal(raw, &profiles); err != nil {
+			problems = append(problems, "$.profiles: must be an object of profiles")
+		}
+	}
+	for name, raw := range profiles {
+		path := "$.profiles." + name
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: must be an object of inputs", path))
+			continue
+		}
+		for k := range values {
+			if profileForbidden[k] {
+				problems = append(problems, fmt.Sprintf("%s.%s: cannot be set in a profile", path, k))
+				delete(values, k)
+			}
+		}
+		_, p := parseInputValues(values, path)
+		problems = append(problems, p...)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// validateInputs checks the config file and inputsJson without contacting
+// Google Drive, failing with every problem found
+func validateInputs() {
+	var problems []string
+	path := getInputUnenforced(configFileInput)
+	if path == "" {
+		path = defaultConfigFile
+	}
+	if _, err := os.Stat(path); err == nil || getInputUnenforced(configFileInput) != "" {
+		for _, p := range validateConfigFile(path) {
+			problems = append(problems, path+": "+p)
+		}
+		fmt.Printf("Validated %s\n", path)
+	}
+	if doc := getInputUnenforced(inputsJsonInput); doc != "" {
+		_, p := parseInputsJson([]byte(doc), "$")
+		for _, p := range p {
+			problems = append(problems, inputsJsonInput+": "+p)
+		}
+		fmt.Printf("Validated %v\n", inputsJsonInput)
+	}
+	if len(problems) > 0 {
+		invalidInput(fmt.Sprintf("%d problem(s) found:\n  %s", len(problems), strings.Join(problems, "\n  ")))
+	}
+	fmt.Println("No problem found")
+}
+
+// validateCommand runs the validate subcommand
+func validateCommand(args []string) {
+	cliMode = true
+	fs := flag.NewFlagSet("gdrive-upload validate", flag.ExitOnError)
+	configFile := fs.String(configFileInput, "", "path of the config file, "+defaultConfigFile+" by default")
+	inputsJson := fs.String(inputsJsonInput, "", "JSON object carrying the inputs")
+	fs.Parse(args)
+	cliValues[configFileInput] = configFile
+	cliValues[inputsJsonInput] = inputsJson
+	validateInputs()
+}
+
+// inputsSchema returns the JSON schema of an object of inputs
+func inputsSchema(exclude map[string]bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, in := range cliInputs {
+		if exclude[in.name] || in.name == inputsJsonInput || in.name == actionInput {
+			continue
+		}
+		property := map[string]interface{}{
+			"description": in.usage,
+			"type":        []string{"string", "number", "boolean", "array"},
+			"items":       map[string]string{"type": "string"},
+		}
+		if jsonInputs[in.name] {
+			property["type"] = []string{"string", "array", "object"}
+			delete(property, "items")
+		}
+		properties[in.name] = property
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// schemaCommand prints the JSON schema of the config file
+func schemaCommand() {
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         schemaId,
+		"title":       "google-drive-upload-git-action config file",
+		"type":        "object",
+		"definitions": map[string]interface{}{"profile": inputsSchema(profileForbidden), "inputs": inputsSchema(nil)},
+		"properties": map[string]interface{}{
+			"$schema":  map[string]string{"type": "string"},
+			"profiles": map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"$ref": "#/definitions/profile"}},
+		},
+		"additionalProperties": false,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fatalf(fmt.Sprintf("encoding schema failed with error: %v", err))
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+	"google.golang.org/api/drive/v3"
+)
+
+const waitForVisibilityInput = "waitForVisibility"
+
+var visibilityTimeout time.Duration
+
+// waitForVisibility polls the listings of the folders the files of the run
+// were uploaded to until all of them are listed, for at most the duration
+// given by waitForVisibility, so that the status file, the manifest and the
+// pinned shortcuts are only updated once consumers can see the files
+func waitForVisibility(svc *drive.Service) {
+	timeout := visibilityTimeout
+	if timeout == 0 {
+		return
+	}
+	pending := map[string]map[string]bool{}
+	for _, f := range runManifest.Files {
+		if f.ParentId == "" {
+			continue
+		}
+		if pending[f.ParentId] == nil {
+			pending[f.ParentId] = map[string]bool{}
+		}
+		pending[f.ParentId][f.FileId] = true
+	}
+	if len(pending) == 0 {
+		return
+	}
+	fmt.Printf("Waiting up to %v for the uploaded files to be listed\n", timeout)
+	deadline := time.Now().Add(timeout)
+	delay := time.Second
+	for {
+		missing := 0
+		for parentId, ids := range pending {
+			children, err := listChildren(svc, parentId, "id")
+			if err != nil {
+				githubactions.Warningf(fmt.Sprintf("listing folder %s failed with error: %v", parentId, err))
+				missing += len(ids)
+				continue
+			}
+			for _, c := range children {
+				delete(ids, c.Id)
+			}
+			if len(ids) == 0 {
+				delete(pending, parentId)
+			}
+			missing += len(ids)
+		}
+		if missing == 0 {
+			fmt.Println("All uploaded files are listed")
+			return
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			githubactions.Warningf(fmt.Sprintf("%d uploaded file(s) are still not listed after %v", missing, timeout))
+			return
+		}
+		wait := delay
+		if wait > left {
+			// check once more at the deadline
+			wait = left
+		}
+		fmt.Printf("%d uploaded file(s) not listed yet, checking again in %s\n", missing, formatDuration(wait))
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
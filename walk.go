@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	listConcurrencyInput   = "listConcurrency"
+	defaultListConcurrency = 4
+	folderMimeType         = "application/vnd.google-apps.folder"
+)
+
+// listConcurrency is the number of folders listed at the same time when
+// walking a remote tree
+var listConcurrency = defaultListConcurrency
+
+// walkTree calls visit with every file and folder in the subtree of a folder
+// and the path of the folder it is in. Sibling folders are listed
+// concurrently, while visit is always called from the calling goroutine, in
+// no particular order. fields must include id, name and mimeType.
+func walkTree(svc *drive.Service, folderId string, fields string, visit func(folderPath string, f *drive.File)) error {
+	type folder struct{ id, path string }
+	type listing struct {
+		folder
+		files []*drive.File
+		err   error
+	}
+	pending := []folder{{folderId, ""}}
+	results := make(chan listing)
+	running := 0
+	for len(pending) > 0 || running > 0 {
+		for len(pending) > 0 && running < listConcurrency {
+			current := pending[0]
+			pending = pending[1:]
+			running++
+			go func() {
+				files, err := listChildren(svc, current.id, fields)
+				results <- listing{current, files, err}
+			}()
+		}
+		l := <-results
+		running--
+		if l.err != nil {
+			for ; running > 0; running-- {
+				<-results
+			}
+			return fmt.Errorf("listing folder %s failed with error: %w", "/"+l.path, l.err)
+		}
+		for _, f := range l.files {
+			visit(l.path, f)
+			if f.MimeType == folderMimeType {
+				pending = append(pending, folder{f.Id, remotePath(l.path, f.Name)})
+			}
+		}
+	}
+	return nil
+}
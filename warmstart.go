@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	warmStartInput  = "warmStart"
+	warmStartFields = "id,name,mimeType,parents,version,md5Checksum,size,description,appProperties,modifiedTime,createdTime"
+)
+
+// remoteTree is a snapshot of the destination subtree, listed once before
+// planning so that files and folders are looked up without a request each
+type remoteTree struct {
+	// children are the files and folders of each folder of the subtree by
+	// their NFC name
+	children map[string]map[string][]*drive.File
+}
+
+// warmTree is the snapshot of the destination, if warmStart is enabled
+var warmTree *remoteTree
+
+// loadRemoteTree lists the subtree of the destination folder
+func loadRemoteTree(svc *drive.Service, folderId string) {
+	fmt.Printf("Listing the files in folder %s\n", folderId)
+	start := time.Now()
+	t := &remoteTree{children: map[string]map[string][]*drive.File{folderId: {}}}
+	count := 0
+	err := walkTree(svc, folderId, warmStartFields, func(folderPath string, f *drive.File) {
+		count++
+		for _, parent := range f.Parents {
+			if t.children[parent] == nil {
+				t.children[parent] = map[string][]*drive.File{}
+			}
+			t.children[parent][nfc(f.Name)] = append(t.children[parent][nfc(f.Name)], f)
+		}
+		if f.MimeType == folderMimeType && t.children[f.Id] == nil {
+			t.children[f.Id] = map[string][]*drive.File{}
+		}
+	})
+	if err != nil {
+		fatalf(fmt.Sprintf("listing folder %s failed with error: %v", folderId, err))
+	}
+	fmt.Printf("Listed %d file(s) and folder(s) in %s\n", count, formatDuration(time.Since(start)))
+	warmTree = t
+}
+
+// lookup returns the files and folders named name in a folder, and whether
+// the folder is part of the snapshot
+func (t *remoteTree) lookup(folderId string, name string) ([]*drive.File, bool) {
+	if t == nil {
+		return nil, false
+	}
+	children, ok := t.children[folderId]
+	if !ok {
+		return nil, false
+	}
+	return children[nfc(name)], true
+}
+
+// findDirectory returns the id of a folder like findDriveDirectory, from the
+// snapshot of the destination when there is one
+func (p *planner) findDirectory(parentId string, name string) string {
+	files, ok := warmTree.lookup(parentId, name)
+	if !ok {
+		return findDriveDirectory(p.svc, parentId, name)
+	}
+	// pick the oldest folder, like findDriveDirectory
+	var next *drive.File
+	for _, f := range files {
+		if f.MimeType != folderMimeType {
+			continue
+		}
+		if next == nil || f.CreatedTime < next.CreatedTime || (f.CreatedTime == next.CreatedTime && f.Id < next.Id) {
+			next = f
+		}
+	}
+	if next == nil {
+		return ""
+	}
+	return next.Id
+}
+
+// findFile returns the file named name in a folder, from the snapshot of the
+// destination when there is one
+func (p *planner) findFile(parentId string, name string) *drive.File {
+	files, ok := warmTree.lookup(parentId, name)
+	if !ok {
+		return findDriveFile(p.svc, parentId, name)
+	}
+	return firstFile(files)
+}
+
+// findFileInFolder is findDriveFileInFolder, from the snapshot of the
+// destination when there is one
+func (p *planner) findFileInFolder(parentId string, name string) *drive.File {
+	files, ok := warmTree.lookup(parentId, name)
+	if !ok {
+		return findDriveFileInFolder(p.svc, parentId, name)
+	}
+	return firstFile(files)
+}
+
+// firstFile prefers files over folders of the same name
+func firstFile(files []*drive.File) *drive.File {
+	for _, f := range files {
+		if f.MimeType != folderMimeType {
+			return f
+		}
+	}
+	if len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}